@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"breeze/ast"
+)
+
+// Control flow of statements, used to find missing returns and unreachable code
+
+// terminates reports whether the statement node never completes normally, so statements after it are not reached
+func terminates(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.ReturnStmt, *ast.BreakStmt, *ast.ContinueStmt:
+		return true
+	case *ast.ClosureStmt:
+		return terminates(n.Block)
+	case *ast.BlockStmt:
+		for _, statement := range n.Nodes {
+			if terminates(statement) {
+				return true
+			}
+		}
+		return false
+	case *ast.ConditionalStmt:
+		// Without else the condition may be false
+		if n.Statement == nil || n.ElseStatement == nil {
+			return false
+		}
+		return terminates(n.Statement) && terminates(n.ElseStatement)
+	case *ast.WhileStmt:
+		// Only an endless loop without break never completes
+		return isTrue(n.Condition) && !breaks(n.Statement)
+	case *ast.ForStmt:
+		return (n.Condition == nil || isTrue(n.Condition)) && !breaks(n.Statement)
+	case *ast.ExprStmt:
+		match, ok := n.Expression.(*ast.MatchExpr)
+		if !ok || !exhaustive(match) {
+			return false
+		}
+		for _, arm := range match.Arms {
+			if !terminates(arm) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// breaks reports whether node contains a break of the loop it is the body of, breaks of nested loops do not count
+func breaks(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.BreakStmt:
+		return true
+	case *ast.ClosureStmt:
+		return breaks(n.Block)
+	case *ast.BlockStmt:
+		for _, statement := range n.Nodes {
+			if breaks(statement) {
+				return true
+			}
+		}
+		return false
+	case *ast.ConditionalStmt:
+		return breaks(n.Statement) || breaks(n.ElseStatement)
+	case *ast.ExprStmt:
+		if match, ok := n.Expression.(*ast.MatchExpr); ok {
+			for _, arm := range match.Arms {
+				if breaks(arm) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// exhaustive reports whether the patterns of match cover every value, with _ or both bool values
+func exhaustive(match *ast.MatchExpr) bool {
+	matchesTrue, matchesFalse := false, false
+	for _, alternatives := range match.Patterns {
+		if len(alternatives) == 0 {
+			return true
+		}
+		for _, pattern := range alternatives {
+			if literal, ok := pattern.(*ast.BooleanLitExpr); ok {
+				matchesTrue = matchesTrue || literal.Value == "true"
+				matchesFalse = matchesFalse || literal.Value != "true"
+			}
+		}
+	}
+	return matchesTrue && matchesFalse
+}
+
+func isTrue(condition ast.Node) bool {
+	literal, ok := condition.(*ast.BooleanLitExpr)
+	return ok && literal.Value == "true"
+}
+
+// assignments are the variables that are not definitely assigned on a path, mapped to whether they are assigned on
+// some other path that joined it
+type assignments map[*variable]bool
+
+// loop is a loop enclosing the current statement
+type loop struct {
+	Node ast.Node
+	// Assignments at the breaks of the loop, nil without breaks
+	Breaks assignments
+	// Assignments at the continues of the loop, nil without continues
+	Continues assignments
+}
+
+func (a assignments) clone() assignments {
+	cloned := make(assignments, len(a))
+	for v, partially := range a {
+		cloned[v] = partially
+	}
+	return cloned
+}
+
+// join merges the assignments at the end of two paths, a variable assigned on only one of them is partially assigned
+func join(a assignments, b assignments) assignments {
+	joined := a.clone()
+	for v, partially := range b {
+		_, ok := a[v]
+		joined[v] = !ok || partially || a[v]
+	}
+	for v := range a {
+		if _, ok := b[v]; !ok {
+			joined[v] = true
+		}
+	}
+	return joined
+}
@@ -3,10 +3,11 @@ package analyzer
 import (
 	"breeze/ast"
 	"breeze/common"
-	"breeze/out"
 	"breeze/scanner"
 	"fmt"
-	"os"
+	"math"
+	"math/big"
+	"strconv"
 )
 
 type DeclarationType uint8
@@ -16,6 +17,25 @@ const (
 	FunctionDeclaration
 )
 
+// Diagnostic codes of the static analysis
+const (
+	codeErrNode          = "BZ0300"
+	codeUndeclared       = "BZ0301"
+	codeRedeclared       = "BZ0302"
+	codeTypeMismatch     = "BZ0303"
+	codeInvalidOperation = "BZ0304"
+	codeUndefined        = "BZ0305"
+	codeInvalidReturn    = "BZ0306"
+	codeArgumentCount    = "BZ0307"
+	codeUnknownField     = "BZ0308"
+	codeInferType        = "BZ0309"
+	codeOutsideLoop      = "BZ0310"
+	codeUnreachable      = "BZ0311"
+	codeOutOfRange       = "BZ0312"
+	codeDuplicatePattern = "BZ0313"
+	codeNotExhaustive    = "BZ0314"
+)
+
 type ReferenceType uint8
 
 const (
@@ -27,19 +47,41 @@ const (
 var initialNode = &ast.ErrNode{Token: scanner.Token{Id: scanner.EOF, Position: common.InitPosition()}, Message: "INITIAL", Hint: ""}
 
 var (
-	TypeNoReference    = &staticType{TypeName: "undef_type", DeclaredAt: initialNode}
-	TypeVoidReference  = &staticType{TypeName: "void", DeclaredAt: initialNode}
-	TypeIntReference   = &staticType{TypeName: "int", DeclaredAt: initialNode}
-	TypeFloatReference = &staticType{TypeName: "float", DeclaredAt: initialNode}
-	TypeBoolReference  = &staticType{TypeName: "bool", DeclaredAt: initialNode}
+	TypeNoReference     = &staticType{TypeName: "undef_type", DeclaredAt: initialNode}
+	TypeVoidReference   = &staticType{TypeName: "void", DeclaredAt: initialNode}
+	TypeIntReference    = &staticType{TypeName: "int", DeclaredAt: initialNode}
+	TypeFloatReference  = &staticType{TypeName: "float", DeclaredAt: initialNode}
+	TypeBoolReference   = &staticType{TypeName: "bool", DeclaredAt: initialNode}
+	TypeStringReference = &staticType{TypeName: "string", DeclaredAt: initialNode}
+)
+
+// Builtins taking collections of any element type are checked by genericBuiltin, their parameters only give the count
+var (
+	BuiltinLength = &function{FunctionName: "len", DeclaredAt: initialNode, ReturnType: TypeIntReference, ParameterTypes: []*staticType{TypeNoReference}}
+	BuiltinAppend = &function{FunctionName: "append", DeclaredAt: initialNode, ReturnType: TypeNoReference, ParameterTypes: []*staticType{TypeNoReference, TypeNoReference}}
 )
 
 func declareTypes(context *Context) {
 	context.declare(TypeNoReference, initialNode)
 	context.declare(TypeVoidReference, initialNode)
-	context.declare(TypeIntReference, initialNode)
-	context.declare(TypeFloatReference, initialNode)
 	context.declare(TypeBoolReference, initialNode)
+	context.declare(TypeStringReference, initialNode)
+
+	for _, name := range ast.NumberTypeNames {
+		switch name {
+		case TypeIntReference.TypeName:
+			context.declare(TypeIntReference, initialNode)
+		case TypeFloatReference.TypeName:
+			context.declare(TypeFloatReference, initialNode)
+		default:
+			context.declare(&staticType{TypeName: name, DeclaredAt: initialNode}, initialNode)
+		}
+	}
+}
+
+func declareBuiltins(context *Context) {
+	context.declare(BuiltinLength, initialNode)
+	context.declare(BuiltinAppend, initialNode)
 }
 
 func compareType(a staticType, b staticType) bool {
@@ -84,6 +126,12 @@ type staticType struct {
 	Parent     *staticType
 	DeclaredAt ast.Node
 	TypeName   string
+	// Fields of struct types in declaration order, empty for primitive types
+	FieldNames []string
+	FieldTypes []*staticType
+	// Element type of arrays and slices, Length is zero for slices
+	Element *staticType
+	Length  int
 }
 
 func (s *staticType) RefType() ReferenceType {
@@ -102,12 +150,52 @@ func (s *staticType) Static() *staticType {
 	return s
 }
 
+func (s *staticType) isStruct() bool {
+	_, ok := s.DeclaredAt.(*ast.StructDecl)
+	return ok
+}
+
+func (s *staticType) isArray() bool {
+	return s.Element != nil && s.Length > 0
+}
+
+func (s *staticType) isNumber() bool {
+	_, ok := ast.NumberType(s.TypeName)
+	return ok
+}
+
+func (s *staticType) isInteger() bool {
+	return ast.IsInteger(s.TypeName)
+}
+
+func (s *staticType) isUnsigned() bool {
+	number, ok := ast.NumberType(s.TypeName)
+	return ok && number.Kind == ast.Unsigned
+}
+
+func (s *staticType) isFloat() bool {
+	number, ok := ast.NumberType(s.TypeName)
+	return ok && number.Kind == ast.Floating
+}
+
+func (s *staticType) isSlice() bool {
+	return s.Element != nil && s.Length == 0
+}
+
+func (s *staticType) field(name string) (*staticType, bool) {
+	for i, fieldName := range s.FieldNames {
+		if fieldName == name {
+			return s.FieldTypes[i], true
+		}
+	}
+	return TypeVoidReference, false
+}
+
 type variable struct {
 	staticDeclaration
 	DeclaredAt   ast.Node
 	VariableName string
 	VariableType *staticType
-	Initialized  bool
 }
 
 func (v *variable) RefType() ReferenceType {
@@ -164,23 +252,75 @@ func initScope() Scope {
 type Context struct {
 	ast.Visitor
 	File            common.SourceFile
-	Source          string
-	HadError        bool
+	Diagnostics     *common.DiagnosticBag
 	Stack           []Scope
 	CurrentFunction *function
+	// Functions declared before visiting their declaration
+	Hoisted map[ast.Node]*function
+	// Loops enclosing the current statement, innermost last
+	Loops []*loop
+	// Declared variables that are not definitely assigned at the current statement
+	Unassigned assignments
+}
+
+func Analyze(sourceFile common.SourceFile, nodes []ast.Node) *common.DiagnosticBag {
+	context := InitContext(sourceFile)
+	context.analyze(nodes)
+	context.end()
+	return context.Diagnostics
 }
 
-func Analyze(sourceFile common.SourceFile, source string, nodes []ast.Node) bool {
-	context := &Context{Stack: make([]Scope, 0), HadError: false, CurrentFunction: nil, Source: source, File: sourceFile}
+// InitContext creates a context with the builtin types and functions declared
+func InitContext(sourceFile common.SourceFile) *Context {
+	context := &Context{Stack: make([]Scope, 0), Diagnostics: common.InitDiagnosticBag(), CurrentFunction: nil, File: sourceFile, Hoisted: make(map[ast.Node]*function), Unassigned: make(assignments)}
 	context.begin()
 	declareTypes(context)
+	declareBuiltins(context)
+	return context
+}
 
+func (c *Context) analyze(nodes []ast.Node) {
+	// Structs first, as function signatures may use them
 	for _, node := range nodes {
-		_ = node.Visit(context)
+		if node.GetId() == ast.StructId {
+			_ = node.Visit(c)
+		}
 	}
+	c.hoist(nodes)
 
-	context.end()
-	return context.HadError
+	for _, node := range nodes {
+		if node.GetId() != ast.StructId {
+			_ = node.Visit(c)
+		}
+	}
+}
+
+// Check analyzes nodes in a new scope on top of the declarations of previous calls, so they may shadow them.
+// The scope is kept if there are no errors, until Discard removes it.
+func (c *Context) Check(nodes []ast.Node) *common.DiagnosticBag {
+	c.Diagnostics = common.InitDiagnosticBag()
+	c.begin()
+	c.analyze(nodes)
+	if c.Diagnostics.HasErrors() {
+		c.end()
+	}
+	return c.Diagnostics
+}
+
+// Discard removes the declarations of the last successful Check
+func (c *Context) Discard() {
+	if len(c.Stack) > 1 {
+		c.end()
+	}
+}
+
+// TypeOf returns the type name of expression without keeping any declarations
+func (c *Context) TypeOf(expression ast.Node) (string, *common.DiagnosticBag) {
+	c.Diagnostics = common.InitDiagnosticBag()
+	c.begin()
+	typeName := c.visitValue(expression, TypeNoReference).TypeName
+	c.end()
+	return typeName, c.Diagnostics
 }
 
 func (c *Context) push(scope Scope) {
@@ -203,26 +343,22 @@ func (c *Context) empty() bool {
 	return len(c.Stack) == 0
 }
 
-func (c *Context) nodeError(node ast.Node, message string) {
-	c.HadError = true
-	out.PrintErrorMessage(message)
-	token := node.GetToken()
-	out.PrintErrorSource(c.File.Path, token.Position)
-	out.PrintMarkedLine(os.Stderr, c.Source, token.LexemeLength(), token.Position, out.ColorRed, '^')
-}
+func (c *Context) span(node ast.Node) common.Span {
+	if node == initialNode {
+		// Builtin declarations have no source location
+		return common.Span{}
+	}
 
-func (c *Context) nodeHelpHint(node ast.Node, message string) {
 	token := node.GetToken()
-	out.PrintErrorSource(c.File.Path, token.Position)
-	out.PrintMarkedLine(os.Stderr, c.Source, token.LexemeLength(), token.Position, out.ColorBlue, '-')
-	out.PrintHintMessage(message, out.ColorBlue)
+	return token.Span(c.File)
 }
 
-func (c *Context) comparativeError(cause ast.Node, causeMessage string, where ast.Node, whereMessage string) {
-	c.HadError = true
+func (c *Context) nodeError(node ast.Node, code string, message string) *common.Diagnostic {
+	return c.Diagnostics.Error(code, message, c.span(node))
+}
 
-	c.nodeError(cause, causeMessage)
-	c.nodeHelpHint(where, whereMessage)
+func (c *Context) comparativeError(cause ast.Node, code string, causeMessage string, where ast.Node, whereMessage string) *common.Diagnostic {
+	return c.nodeError(cause, code, causeMessage).Label(c.span(where), whereMessage)
 }
 
 func (c *Context) lookup(declName string) (staticDeclaration, bool) {
@@ -236,25 +372,140 @@ func (c *Context) lookup(declName string) (staticDeclaration, bool) {
 	return TypeVoidReference, false
 }
 
-func (c *Context) lookupType(node ast.Node, typeName string) (*staticType, bool) {
+// lookupType returns the type of typeName, errors are reported at the span of the type
+func (c *Context) lookupType(at common.Span, typeName string) (*staticType, bool) {
 	if len(typeName) == 0 {
 		return TypeNoReference, true
 	}
 
+	if elementName, length, ok := ast.ArrayType(typeName); ok {
+		element, ok := c.lookupElementType(at, elementName)
+		if !ok {
+			return TypeVoidReference, false
+		}
+		return &staticType{DeclaredAt: initialNode, TypeName: typeName, Element: element, Length: length}, true
+	}
+
+	if elementName, ok := ast.SliceType(typeName); ok {
+		element, ok := c.lookupElementType(at, elementName)
+		if !ok {
+			return TypeVoidReference, false
+		}
+		return &staticType{DeclaredAt: initialNode, TypeName: typeName, Element: element}, true
+	}
+
 	declType, ok := c.lookup(typeName)
 	if !ok {
-		c.nodeError(node, fmt.Sprintf("Undeclared type %s", typeName))
+		c.Diagnostics.Error(codeUndeclared, fmt.Sprintf("Undeclared type %s", typeName), at)
 		return TypeVoidReference, false
 	}
 	if declType.RefType() != TypeReference {
-		c.nodeError(node, "Invalid type")
-		c.comparativeError(node, "Invalid type", declType.Node(), "This is not a type")
+		c.Diagnostics.Error(codeTypeMismatch, "Invalid type", at).Label(c.span(declType.Node()), "This is not a type")
 		return TypeVoidReference, false
 	}
 	staticDeclType := declType.(*staticType)
 	return staticDeclType, true
 }
 
+func (c *Context) lookupElementType(at common.Span, typeName string) (*staticType, bool) {
+	element, ok := c.lookupType(at, typeName)
+	if !ok {
+		return TypeVoidReference, false
+	}
+	if compareType(*element, *TypeNoReference) || compareType(*element, *TypeVoidReference) {
+		c.Diagnostics.Error(codeTypeMismatch, fmt.Sprintf("Invalid element type %s", element.TypeName), at)
+		return TypeVoidReference, false
+	}
+	return element, true
+}
+
+// visitValue checks a value that is stored with the expected type, which gives array and number literals their type
+func (c *Context) visitValue(value ast.Node, expect *staticType) *staticType {
+	if expect == nil {
+		expect = TypeNoReference
+	}
+
+	switch node := value.(type) {
+	case *ast.ArrayLitExpr:
+		return c.arrayLiteral(node, expect)
+	case *ast.IntegerLitExpr:
+		return c.integerLiteral(node, false, expect)
+	case *ast.FloatingLitExpr:
+		return c.floatLiteral(node, expect)
+	case *ast.UnaryExpr:
+		return c.unary(node, expect)
+	case *ast.BinaryExpr:
+		return c.binary(node, expect)
+	case *ast.MatchExpr:
+		return c.match(node, false, expect)
+	}
+	return value.Visit(c).(staticDeclaration).Static()
+}
+
+// untyped reports whether the type of node is given by the expected type, like for number literals and operations on them
+func untyped(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.IntegerLitExpr, *ast.FloatingLitExpr:
+		return true
+	case *ast.UnaryExpr:
+		return n.Operator.Id != scanner.Bang && untyped(n.Expression)
+	case *ast.BinaryExpr:
+		return !isComparison(n.Operator.Id) && untyped(n.Left) && untyped(n.Right)
+	}
+	return false
+}
+
+func isComparison(operator scanner.TokenId) bool {
+	switch operator {
+	case scanner.Lower, scanner.Greater, scanner.LowerEquals, scanner.GreaterEquals, scanner.EqualsEquals, scanner.BangEquals, scanner.AndAnd, scanner.PipePipe:
+		return true
+	}
+	return false
+}
+
+// isIntegerOperator reports whether operator only applies to integers, the bits of floats are not exposed
+func isIntegerOperator(operator scanner.TokenId) bool {
+	switch operator {
+	case scanner.Percent, scanner.And, scanner.Pipe, scanner.Caret, scanner.LowerLower, scanner.GreaterGreater:
+		return true
+	}
+	return false
+}
+
+const integerOperatorHint = "Operators %, &, |, ^, << and >> are possible on integer types"
+
+// castHint suggests to convert value to expect if both are numeric types, which are never converted implicitly
+func castHint(value ast.Node, valueType *staticType, expect *staticType, hint string) string {
+	if !valueType.isNumber() || !expect.isNumber() {
+		return hint
+	}
+
+	text := "value"
+	switch node := value.(type) {
+	case *ast.IdentifierLitExpr:
+		text = node.Name
+	case *ast.IntegerLitExpr, *ast.FloatingLitExpr:
+		text = node.GetToken().Lexeme
+	}
+	return fmt.Sprintf("Convert the value like: %s as %s", text, expect.TypeName)
+}
+
+// assignable reports whether target, the base of a field or element assignment, refers to a variable
+func assignable(target ast.Node) bool {
+	for {
+		switch target.GetId() {
+		case ast.IdentifierLitId:
+			return true
+		case ast.GetId:
+			target = target.(*ast.GetExpr).Expression
+		case ast.IndexId:
+			target = target.(*ast.IndexExpr).Expression
+		default:
+			return false
+		}
+	}
+}
+
 func (c *Context) declare(staticDecl staticDeclaration, node ast.Node) {
 	// Shadowed variables will be allowed (for now?)
 	declName := staticDecl.Name()
@@ -262,46 +513,114 @@ func (c *Context) declare(staticDecl staticDeclaration, node ast.Node) {
 	top := c.top()
 	prev, ok := top.Declared[declName]
 	if ok {
-		c.comparativeError(node, "Already declared", prev.Node(), "Declared here")
+		c.comparativeError(node, codeRedeclared, "Already declared", prev.Node(), "Declared here")
 		return
 	}
 
 	top.Declared[declName] = staticDecl
 }
 
-func (c *Context) define(name string, at ast.Node, value ast.Node) {
+func (c *Context) define(name string, at ast.Node, value ast.Node) *staticType {
 	decl, ok := c.lookup(name)
 
 	if !ok {
-		c.nodeError(at, "Cannot define undeclared identifier")
-		return
+		c.nodeError(at, codeUndeclared, "Cannot define undeclared identifier")
+		return TypeVoidReference
 	}
 
-	if decl.RefType() == VariableReference {
-		varDecl := decl.(*variable)
-		varDecl.Initialized = true
+	if decl.RefType() != VariableReference {
+		c.comparativeError(at, codeInvalidOperation, "Cannot assign to non variable", decl.Node(), "Declared here")
+		return TypeVoidReference
+	}
 
-		inferredType := value.Visit(c).(staticDeclaration).Static()
-		if compareType(*varDecl.Static(), *TypeNoReference) {
-			varDecl.VariableType = inferredType
-		}
+	varDecl := decl.(*variable)
 
-		if !compareType(*inferredType, *varDecl.VariableType) {
-			c.nodeError(value, "Unexpected type")
-			out.PrintHintMessage(fmt.Sprintf("Expcted value of type %s", varDecl.VariableType.TypeName), out.ColorRed)
-			return
-		}
+	inferredType := c.visitValue(value, varDecl.VariableType)
+	// Assigned after the value is evaluated, which may not read the variable
+	delete(c.Unassigned, varDecl)
+	if compareType(*varDecl.Static(), *TypeNoReference) {
+		varDecl.VariableType = inferredType
+	}
 
-		// CONTEXT: Set type in node
-		if decl.Node().GetId() == ast.LetId {
-			letDecl := varDecl.DeclaredAt.(*ast.LetDecl)
-			letDecl.Type = varDecl.VariableType.TypeName
-		}
+	if !compareType(*inferredType, *varDecl.VariableType) {
+		c.nodeError(value, codeTypeMismatch, "Unexpected type").Hint(castHint(value, inferredType, varDecl.VariableType, fmt.Sprintf("Expected value of type %s", varDecl.VariableType.TypeName)))
+		return TypeVoidReference
+	}
 
+	// CONTEXT: Set type in node
+	if decl.Node().GetId() == ast.LetId {
+		letDecl := varDecl.DeclaredAt.(*ast.LetDecl)
+		letDecl.Type = varDecl.VariableType.TypeName
 	}
 
 	scope := c.top()
 	scope.Declared[name] = decl
+
+	return varDecl.VariableType
+}
+
+// update checks a compound assignment like += to an already defined variable
+func (c *Context) update(name string, at *ast.AssignExpr) *staticType {
+	decl, ok := c.lookup(name)
+
+	if !ok {
+		c.nodeError(at, codeUndeclared, "Undeclared identifier")
+		return TypeVoidReference
+	}
+
+	if decl.RefType() != VariableReference {
+		c.comparativeError(at, codeInvalidOperation, "Cannot assign to non variable", decl.Node(), "Declared here")
+		return TypeVoidReference
+	}
+
+	varDecl := decl.(*variable)
+	c.assigned(at, varDecl)
+	// Assigned by the update, uninitialized variables are reported only once
+	delete(c.Unassigned, varDecl)
+
+	varType := varDecl.VariableType
+	if !c.compound(at, at.Operator, varType) {
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set variable type in node
+	at.Type = varType.TypeName
+
+	valueType := c.visitValue(at.Value, varType)
+	if !compareType(*valueType, *varType) {
+		c.nodeError(at.Value, codeTypeMismatch, "Unexpected type").Hint(castHint(at.Value, valueType, varType, fmt.Sprintf("Expected value of type %s", varType.TypeName)))
+		return TypeVoidReference
+	}
+
+	return varType
+}
+
+// compound reports an error if the compound assignment operator cannot update a value of targetType
+func (c *Context) compound(at ast.Node, operator scanner.Token, targetType *staticType) bool {
+	binary, _ := scanner.CompoundOperator(operator.Id)
+	if !targetType.isNumber() {
+		c.nodeError(at, codeInvalidOperation, fmt.Sprintf("Compound assignment on type %s", targetType.TypeName)).Hint("Compound assignment possible on numeric types")
+		return false
+	}
+	if isIntegerOperator(binary) && !targetType.isInteger() {
+		c.nodeError(at, codeInvalidOperation, fmt.Sprintf("Compound assignment %s on type %s", operator.Lexeme, targetType.TypeName)).Hint(integerOperatorHint)
+		return false
+	}
+	return true
+}
+
+// assigned reports an error if variable may be read at node before it is assigned
+func (c *Context) assigned(node ast.Node, variable *variable) {
+	partially, ok := c.Unassigned[variable]
+	if !ok {
+		return
+	}
+
+	message := "Uninitialized variable"
+	if partially {
+		message = "Possibly uninitialized variable"
+	}
+	c.comparativeError(node, codeUndefined, message, variable.DeclaredAt, "Declared without value").Hint(fmt.Sprintf("Assign %s on every path before reading it", variable.VariableName))
 }
 
 func (c *Context) begin() {
@@ -318,16 +637,14 @@ func (c *Context) VisitIdentifierLitExpr(node *ast.IdentifierLitExpr) any {
 	decl, ok := c.lookup(name)
 
 	if !ok {
-		c.nodeError(node, "Undeclared identifier")
+		c.nodeError(node, codeUndeclared, "Undeclared identifier")
 		return TypeVoidReference
 	}
 
 	if decl.RefType() == VariableReference {
+		// The type is known even if the value is not, so reading it does not cause further errors
 		variable := decl.(*variable)
-		if !variable.Initialized {
-			c.nodeError(node, "Undefined variable")
-			return TypeVoidReference
-		}
+		c.assigned(node, variable)
 		return variable
 	}
 
@@ -336,19 +653,21 @@ func (c *Context) VisitIdentifierLitExpr(node *ast.IdentifierLitExpr) any {
 func (c *Context) VisitLetDecl(node *ast.LetDecl) any {
 	declName := node.Identifier
 
-	declType, ok := c.lookupType(node, node.Type)
+	declType, ok := c.lookupType(c.span(node), node.Type)
 	if !ok {
 		return TypeVoidReference
 	}
-	decl := &variable{DeclaredAt: node, Initialized: false, VariableName: declName, VariableType: declType}
+	decl := &variable{DeclaredAt: node, VariableName: declName, VariableType: declType}
 	c.declare(decl, node)
+	c.Unassigned[decl] = false
 	return TypeVoidReference
 }
-func (c *Context) VisitFunctionDecl(node *ast.FunctionDecl) any {
-	declName := node.Identifier
-	declType, ok := c.lookupType(node, node.ReturnType)
+
+// signature checks the parameter and return types of a function declaration
+func (c *Context) signature(node *ast.FunctionDecl) (*function, bool) {
+	declType, ok := c.lookupType(c.span(node), node.ReturnType)
 	if !ok {
-		return TypeVoidReference
+		return nil, false
 	}
 
 	paramCount := len(node.ParamType)
@@ -356,95 +675,286 @@ func (c *Context) VisitFunctionDecl(node *ast.FunctionDecl) any {
 
 	for i := 0; i < paramCount; i++ {
 		paramTypeName := node.ParamType[i]
-		paramType, ok := c.lookupType(node, paramTypeName)
+		paramType, ok := c.lookupType(c.span(node), paramTypeName)
 		if !ok {
-			return TypeVoidReference
+			return nil, false
 		}
 		parameterTypes = append(parameterTypes, paramType)
 	}
 
-	fn := &function{DeclaredAt: node, FunctionName: declName, ReturnType: declType, ParameterTypes: parameterTypes}
-	c.declare(fn, node)
+	fn := &function{DeclaredAt: node, FunctionName: node.Identifier, ReturnType: declType, ParameterTypes: parameterTypes}
+
+	if fn.FunctionName == "main" && c.CurrentFunction == nil {
+		// The exit code of the program
+		if paramCount != 0 || (!compareType(*declType, *TypeNoReference) && !compareType(*declType, *TypeIntReference)) {
+			c.nodeError(node, codeInvalidReturn, "Invalid signature of main").Hint("Declare main like: fn main() -> int")
+		}
+	}
+
+	return fn, true
+}
 
-	block := node.Closure.(*ast.ClosureStmt).Block
+// hoist declares the signatures of all top level functions, so they can be called before their declaration
+func (c *Context) hoist(nodes []ast.Node) {
+	for _, node := range nodes {
+		if node.GetId() != ast.FunctionId {
+			continue
+		}
+
+		fn, ok := c.signature(node.(*ast.FunctionDecl))
+		if !ok {
+			continue
+		}
+		c.declare(fn, node)
+		c.Hoisted[node] = fn
+	}
+}
+
+func (c *Context) VisitFunctionDecl(node *ast.FunctionDecl) any {
+	if c.CurrentFunction != nil {
+		c.nodeError(node, codeInvalidOperation, "Cannot declare function inside of function").Hint("Declare functions at the top level")
+		return TypeVoidReference
+	}
+
+	fn, ok := c.Hoisted[node]
+	if !ok {
+		// Signature is invalid and already reported
+		return TypeVoidReference
+	}
+	paramCount := len(fn.ParameterTypes)
+	parameterTypes := fn.ParameterTypes
+
+	closure := node.Closure.(*ast.ClosureStmt)
+	block := closure.Block
 
 	c.begin()
 	prev := c.CurrentFunction
 	c.CurrentFunction = fn
+	// Loops outside of the function can not be exited from inside
+	prevLoops := c.Loops
+	c.Loops = nil
+	// The body is not run at the declaration, its assignments do not count after it
+	prevUnassigned := c.Unassigned
+	c.Unassigned = prevUnassigned.clone()
 
 	for i := 0; i < paramCount; i++ {
 		paramName := node.ParamName[i]
-		// Declare "initialized" variable
-		decl := &variable{DeclaredAt: node, VariableType: parameterTypes[i], VariableName: paramName, Initialized: true}
+		// Parameters are assigned by the call
+		decl := &variable{DeclaredAt: node, VariableType: parameterTypes[i], VariableName: paramName}
 		c.declare(decl, node)
 	}
 
 	_ = block.Visit(c)
 
+	if !compareType(*fn.ReturnType, *TypeNoReference) && !terminates(closure) {
+		c.Diagnostics.Error(codeInvalidReturn, "Missing return at end of function", closure.End.Span(c.File)).
+			Label(c.span(node), fmt.Sprintf("Function expects return type of %s", fn.ReturnType.TypeName)).
+			Hint(fmt.Sprintf("Return a value of type %s on every path", fn.ReturnType.TypeName))
+	}
+
 	c.CurrentFunction = prev
+	c.Loops = prevLoops
+	c.Unassigned = prevUnassigned
 	c.end()
 
 	return TypeVoidReference
 }
 func (c *Context) VisitBinaryExpr(node *ast.BinaryExpr) any {
-	leftType := node.Left.Visit(c).(staticDeclaration).Static()
-	rightType := node.Right.Visit(c).(staticDeclaration).Static()
+	return c.binary(node, TypeNoReference)
+}
+
+// binary checks a binary expression whose value is expected to be of type expect.
+// Number literals take the type of the other operand, or the expected type if both are literals.
+func (c *Context) binary(node *ast.BinaryExpr, expect *staticType) *staticType {
+	if isComparison(node.Operator.Id) {
+		expect = TypeNoReference
+	}
+
+	var leftType, rightType *staticType
+	if untyped(node.Left) && !untyped(node.Right) {
+		rightType = c.visitValue(node.Right, expect)
+		leftType = c.visitValue(node.Left, rightType)
+	} else {
+		leftType = c.visitValue(node.Left, expect)
+		rightType = c.visitValue(node.Right, leftType)
+	}
 	combinedType := leftType
 
 	if !compareType(*leftType, *rightType) {
-		c.nodeError(node, "Type mismatch in binary expression")
-		out.PrintHintMessage(fmt.Sprintf("type %s != type %s", leftType.Static().TypeName, rightType.Static().TypeName), out.ColorRed)
+		hint := fmt.Sprintf("type %s != type %s", leftType.TypeName, rightType.TypeName)
+		c.nodeError(node, codeTypeMismatch, "Type mismatch in binary expression").Hint(castHint(node.Right, rightType, leftType, hint))
 		return TypeVoidReference
 	}
 
-	switch node.Operator.Id {
-	case scanner.Lower, scanner.Greater, scanner.LowerEquals, scanner.GreaterEquals, scanner.EqualsEquals, scanner.BangEquals, scanner.AndAnd, scanner.PipePipe:
+	if combinedType.isStruct() {
+		c.nodeError(node, codeInvalidOperation, fmt.Sprintf("Unsupported operation on struct %s", combinedType.TypeName)).Hint("Compare the fields of the struct instead")
+		return TypeVoidReference
+	}
+
+	if combinedType.Element != nil {
+		c.nodeError(node, codeInvalidOperation, fmt.Sprintf("Unsupported operation on type %s", combinedType.TypeName)).Hint("Compare the elements instead")
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set operand type in node
+	node.Type = combinedType.TypeName
+
+	if compareType(*combinedType, *TypeStringReference) {
+		switch node.Operator.Id {
+		case scanner.Plus, scanner.EqualsEquals, scanner.BangEquals:
+			break
+		default:
+			c.nodeError(node, codeInvalidOperation, "Unsupported operation on type string").Hint("Strings support concatenation with + and comparison with == and !=")
+			return TypeVoidReference
+		}
+	}
+
+	if isIntegerOperator(node.Operator.Id) && !combinedType.isInteger() {
+		c.nodeError(node, codeInvalidOperation, fmt.Sprintf("Unsupported operation on type %s", combinedType.TypeName)).Hint(integerOperatorHint)
+		return TypeVoidReference
+	}
+
+	if isComparison(node.Operator.Id) {
 		return TypeBoolReference
 	}
 
 	return combinedType
 }
 
+// integerLimits returns the smallest and largest value of an integer type
+func integerLimits(number ast.Number) (*big.Int, *big.Int) {
+	one := big.NewInt(1)
+	if number.Kind == ast.Unsigned {
+		largest := new(big.Int).Lsh(one, uint(number.Bits))
+		return big.NewInt(0), largest.Sub(largest, one)
+	}
+	largest := new(big.Int).Lsh(one, uint(number.Bits-1))
+	smallest := new(big.Int).Neg(largest)
+	return smallest, largest.Sub(largest, one)
+}
+
+// integerLiteral checks that the value of node fits into the integer type of the literal, which is expect if it is an
+// integer type and int otherwise. Negated literals are checked as a whole, as the smallest value has no positive
+// counterpart.
+func (c *Context) integerLiteral(node *ast.IntegerLitExpr, negated bool, expect *staticType) *staticType {
+	literalType := TypeIntReference
+	if expect.isInteger() {
+		literalType = expect
+	}
+
+	// CONTEXT: Set type in node
+	node.Type = literalType.TypeName
+
+	value, ok := new(big.Int).SetString(node.Value, 10)
+	if !ok {
+		// Malformed literals are reported by the scanner
+		return literalType
+	}
+	if negated {
+		value.Neg(value)
+	}
+
+	number, _ := ast.NumberType(literalType.TypeName)
+	smallest, largest := integerLimits(number)
+	if value.Cmp(smallest) < 0 || value.Cmp(largest) > 0 {
+		c.nodeError(node, codeOutOfRange, fmt.Sprintf("Integer literal out of range for type %s", literalType.TypeName)).
+			Hint(fmt.Sprintf("Values of type %s range from %s to %s", literalType.TypeName, smallest, largest))
+	}
+	return literalType
+}
+
 func (c *Context) VisitIntegerLitExpr(node *ast.IntegerLitExpr) any {
-	return TypeIntReference
+	return c.integerLiteral(node, false, TypeNoReference)
+}
+
+// floatLiteral checks that the value of node fits into the float type of the literal, which is expect if it is a
+// float type and float otherwise
+func (c *Context) floatLiteral(node *ast.FloatingLitExpr, expect *staticType) *staticType {
+	literalType := TypeFloatReference
+	if expect.isFloat() {
+		literalType = expect
+	}
+
+	// CONTEXT: Set type in node
+	node.Type = literalType.TypeName
+
+	number, _ := ast.NumberType(literalType.TypeName)
+	largest := math.MaxFloat64
+	if number.Bits == 32 {
+		largest = math.MaxFloat32
+	}
+
+	// Too large values are parsed as infinity
+	value, _ := strconv.ParseFloat(node.Value, 64)
+	if math.Abs(value) > largest {
+		c.nodeError(node, codeOutOfRange, fmt.Sprintf("Float literal out of range for type %s", literalType.TypeName)).
+			Hint(fmt.Sprintf("Values of type %s are at most %g", literalType.TypeName, largest))
+	}
+	return literalType
 }
 
 func (c *Context) VisitFloatingLitExpr(node *ast.FloatingLitExpr) any {
-	return TypeFloatReference
+	return c.floatLiteral(node, TypeNoReference)
 }
 
 func (c *Context) VisitBooleanLitExpr(node *ast.BooleanLitExpr) any {
 	return TypeBoolReference
 }
 
+func (c *Context) VisitStringLitExpr(node *ast.StringLitExpr) any {
+	return TypeStringReference
+}
+
 func (c *Context) VisitDebugStmt(node *ast.DebugStmt) any {
-	_ = node.Expression.Visit(c)
+	exprType := node.Expression.Visit(c).(staticDeclaration).Static()
+
+	if compareType(*exprType, *TypeVoidReference) || compareType(*exprType, *TypeNoReference) {
+		c.nodeError(node.Expression, codeInvalidOperation, "Expression has no value to debug")
+		return TypeVoidReference
+	}
+
+	if exprType.isStruct() {
+		c.nodeError(node.Expression, codeInvalidOperation, fmt.Sprintf("Cannot debug struct %s", exprType.TypeName)).Hint("Debug the fields of the struct instead")
+		return TypeVoidReference
+	}
+
+	if exprType.Element != nil {
+		c.nodeError(node.Expression, codeInvalidOperation, fmt.Sprintf("Cannot debug type %s", exprType.TypeName)).Hint("Debug the elements instead")
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set type in node
+	node.Type = exprType.TypeName
+
 	return TypeVoidReference
 }
 
 func (c *Context) VisitReturnStmt(node *ast.ReturnStmt) any {
 	if c.CurrentFunction == nil {
-		c.nodeError(node, "Cannot return outside of function")
+		c.nodeError(node, codeInvalidReturn, "Cannot return outside of function")
 		return TypeVoidReference
 	}
 
 	fn := c.CurrentFunction
 
 	if node.Expression == nil && !compareType(*fn.ReturnType, *TypeNoReference) {
-		c.comparativeError(node, fmt.Sprintf("Missing return value"), fn.Node(), fmt.Sprintf("Function expects return value"))
+		c.comparativeError(node, codeInvalidReturn, fmt.Sprintf("Missing return value"), fn.Node(), fmt.Sprintf("Function expects return value"))
 		return TypeVoidReference
 	}
 
 	if node.Expression != nil && compareType(*fn.ReturnType, *TypeNoReference) {
-		c.comparativeError(node, fmt.Sprintf("Present return value"), fn.Node(), fmt.Sprintf("Function does not expect return value"))
+		c.comparativeError(node, codeInvalidReturn, fmt.Sprintf("Present return value"), fn.Node(), fmt.Sprintf("Function does not expect return value"))
 		return TypeVoidReference
 	}
 
 	if node.Expression != nil {
-		returnType := node.Expression.Visit(c).(staticDeclaration).Static()
+		returnType := c.visitValue(node.Expression, fn.ReturnType)
 
 		if !compareType(*returnType, *fn.ReturnType) {
-			c.comparativeError(node, fmt.Sprintf("Invalid return type %s", returnType.TypeName), fn.Node(), fmt.Sprintf("Function expects return type of %s", fn.ReturnType.TypeName))
+			diagnostic := c.comparativeError(node, codeTypeMismatch, fmt.Sprintf("Invalid return type %s", returnType.TypeName), fn.Node(), fmt.Sprintf("Function expects return type of %s", fn.ReturnType.TypeName))
+			if hint := castHint(node.Expression, returnType, fn.ReturnType, ""); len(hint) > 0 {
+				diagnostic.Hint(hint)
+			}
 			return TypeVoidReference
 		}
 	}
@@ -454,10 +964,34 @@ func (c *Context) VisitReturnStmt(node *ast.ReturnStmt) any {
 }
 
 func (c *Context) VisitContinueStmt(node *ast.ContinueStmt) any {
+	if len(c.Loops) == 0 {
+		c.nodeError(node, codeOutsideLoop, "Cannot continue outside of loop").Hint("Use continue inside of a while or for loop")
+		return TypeVoidReference
+	}
+
+	// Continues at the increment of for loops
+	current := c.Loops[len(c.Loops)-1]
+	if current.Continues == nil {
+		current.Continues = c.Unassigned.clone()
+	} else {
+		current.Continues = join(current.Continues, c.Unassigned)
+	}
 	return TypeVoidReference
 }
 
 func (c *Context) VisitBreakStmt(node *ast.BreakStmt) any {
+	if len(c.Loops) == 0 {
+		c.nodeError(node, codeOutsideLoop, "Cannot break outside of loop").Hint("Use break inside of a while or for loop")
+		return TypeVoidReference
+	}
+
+	// Continues after the loop
+	current := c.Loops[len(c.Loops)-1]
+	if current.Breaks == nil {
+		current.Breaks = c.Unassigned.clone()
+	} else {
+		current.Breaks = join(current.Breaks, c.Unassigned)
+	}
 	return TypeVoidReference
 }
 
@@ -465,7 +999,7 @@ func (c *Context) VisitCallExpr(node *ast.CallExpr) any {
 	exprDecl := node.Expression.Visit(c).(staticDeclaration)
 
 	if exprDecl.RefType() != FunctionReference {
-		c.nodeError(node.Expression, "Expected function")
+		c.nodeError(node.Expression, codeInvalidOperation, "Expected function")
 		return TypeVoidReference
 	}
 
@@ -475,15 +1009,22 @@ func (c *Context) VisitCallExpr(node *ast.CallExpr) any {
 	argCount := len(node.Arguments)
 
 	if argCount != paramCount {
-		c.comparativeError(node, "Argument count mismatch", fn.Node(), fmt.Sprintf("Function has %d parameters", paramCount))
+		c.comparativeError(node, codeArgumentCount, "Argument count mismatch", fn.Node(), fmt.Sprintf("Function has %d parameters", paramCount))
 		return TypeVoidReference
 	}
 
+	if fn == BuiltinLength || fn == BuiltinAppend {
+		return c.genericBuiltin(fn, node)
+	}
+
 	for i := 0; i < paramCount; i++ {
-		argType := node.Arguments[i].Visit(c).(staticDeclaration)
+		argType := c.visitValue(node.Arguments[i], fn.ParameterTypes[i])
 		expect := fn.ParameterTypes[i]
-		if !compareType(*argType.Static(), *expect) {
-			c.comparativeError(node, "Invalid argument type", fn.Node(), fmt.Sprintf("Function expects %s at position %d", expect.TypeName, i))
+		if !compareType(*argType, *expect) {
+			diagnostic := c.comparativeError(node, codeTypeMismatch, "Invalid argument type", fn.Node(), fmt.Sprintf("Function expects %s at position %d", expect.TypeName, i))
+			if hint := castHint(node.Arguments[i], argType, expect, ""); len(hint) > 0 {
+				diagnostic.Hint(hint)
+			}
 			return TypeVoidReference
 		}
 	}
@@ -491,37 +1032,184 @@ func (c *Context) VisitCallExpr(node *ast.CallExpr) any {
 	return fn.ReturnType
 }
 
-func (c *Context) VisitConditionalStmt(node *ast.ConditionalStmt) any {
-	conditionType := node.Condition.Visit(c).(staticDeclaration)
+// condition checks the condition of the if or while statement node
+func (c *Context) condition(node ast.Node, condition ast.Node) {
+	conditionType := condition.Visit(c).(staticDeclaration)
 
 	if !compareType(*conditionType.Static(), *TypeBoolReference) {
-		c.comparativeError(node.Condition, "Unexpected condition type", node, fmt.Sprintf("Expected %s", TypeBoolReference.TypeName))
+		c.comparativeError(condition, codeTypeMismatch, "Unexpected condition type", node, fmt.Sprintf("Expected %s", TypeBoolReference.TypeName))
 	}
+}
+
+func (c *Context) VisitConditionalStmt(node *ast.ConditionalStmt) any {
+	c.condition(node, node.Condition)
 
+	before := c.Unassigned.clone()
 	if node.Statement != nil {
 		_ = node.Statement.Visit(c)
 	}
+	then := c.Unassigned
+
+	c.Unassigned = before
 	if node.ElseStatement != nil {
 		_ = node.ElseStatement.Visit(c)
 	}
+	otherwise := c.Unassigned
+
+	// Branches that do not complete do not flow into the following statements
+	switch {
+	case terminates(node.Statement) && !terminates(node.ElseStatement):
+		c.Unassigned = otherwise
+	case terminates(node.ElseStatement) && !terminates(node.Statement):
+		c.Unassigned = then
+	default:
+		c.Unassigned = join(then, otherwise)
+	}
 
 	return TypeVoidReference
 }
 
 func (c *Context) VisitWhileStmt(node *ast.WhileStmt) any {
-	conditionType := node.Condition.Visit(c)
+	c.condition(node, node.Condition)
 
-	if conditionType != "bool" {
-		c.comparativeError(node.Condition, "Unexpected condition type", node, "Expected bool type")
+	before := c.Unassigned.clone()
+	current := &loop{Node: node}
+	c.Loops = append(c.Loops, current)
+	if node.Statement != nil {
+		_ = node.Statement.Visit(c)
+	}
+	c.Loops = c.Loops[:len(c.Loops)-1]
+
+	// The body may not run at all, unless the loop is only left by break
+	after := current.Breaks
+	if !isTrue(node.Condition) {
+		after = join(before, c.Unassigned)
+		if current.Breaks != nil {
+			after = join(after, current.Breaks)
+		}
+	}
+	if after != nil {
+		c.Unassigned = after
 	}
 
+	return TypeVoidReference
+}
+
+func (c *Context) VisitForStmt(node *ast.ForStmt) any {
+	// Variables declared by the initializer are only visible in the loop
+	c.begin()
+	defer c.end()
+
+	if node.Initializer != nil {
+		_ = node.Initializer.Visit(c)
+	}
+	if node.Condition != nil {
+		c.condition(node, node.Condition)
+	}
+
+	before := c.Unassigned.clone()
+	current := &loop{Node: node}
+	c.Loops = append(c.Loops, current)
+	if node.Statement != nil {
+		_ = node.Statement.Visit(c)
+	}
+	c.Loops = c.Loops[:len(c.Loops)-1]
+
+	// The increment is reached from the end of the body and from continue
+	if current.Continues != nil {
+		if terminates(node.Statement) {
+			c.Unassigned = current.Continues
+		} else {
+			c.Unassigned = join(c.Unassigned, current.Continues)
+		}
+	}
+	if node.Increment != nil {
+		_ = node.Increment.Visit(c)
+	}
+
+	// Like while, without condition the loop is only left by break
+	after := current.Breaks
+	if node.Condition != nil && !isTrue(node.Condition) {
+		after = join(before, c.Unassigned)
+		if current.Breaks != nil {
+			after = join(after, current.Breaks)
+		}
+	}
+	if after != nil {
+		c.Unassigned = after
+	}
+
+	return TypeVoidReference
+}
+
+func (c *Context) VisitForInStmt(node *ast.ForInStmt) any {
+	var elementType *staticType
+	if node.End != nil {
+		elementType = c.loopRange(node)
+	} else {
+		collectionType := c.visitValue(node.Expression, nil)
+		elementType = collectionType.Element
+		if elementType == nil {
+			c.nodeError(node.Expression, codeTypeMismatch, fmt.Sprintf("Cannot iterate over type %s", collectionType.TypeName)).
+				Hint("Loops iterate over arrays, slices and ranges like 0..n")
+			elementType = TypeVoidReference
+		}
+
+		// CONTEXT: Set collection type in node
+		node.Type = collectionType.TypeName
+	}
+
+	before := c.Unassigned.clone()
+
+	// Every iteration binds the name to the next value
+	c.begin()
+	decl := &variable{DeclaredAt: node, VariableName: node.Name.Lexeme, VariableType: elementType}
+	c.declare(decl, node)
+
+	current := &loop{Node: node}
+	c.Loops = append(c.Loops, current)
 	if node.Statement != nil {
 		_ = node.Statement.Visit(c)
 	}
+	c.Loops = c.Loops[:len(c.Loops)-1]
+	c.end()
+
+	// The collection or range may be empty
+	after := join(before, c.Unassigned)
+	if current.Breaks != nil {
+		after = join(after, current.Breaks)
+	}
+	c.Unassigned = after
 
 	return TypeVoidReference
 }
 
+// loopRange checks the bounds of a range loop, which are integers of the same type
+func (c *Context) loopRange(node *ast.ForInStmt) *staticType {
+	var startType, endType *staticType
+	if untyped(node.Expression) && !untyped(node.End) {
+		endType = c.visitValue(node.End, nil)
+		startType = c.visitValue(node.Expression, endType)
+	} else {
+		startType = c.visitValue(node.Expression, nil)
+		endType = c.visitValue(node.End, startType)
+	}
+
+	if !startType.isInteger() {
+		c.nodeError(node.Expression, codeTypeMismatch, fmt.Sprintf("Range over type %s", startType.TypeName)).Hint("Ranges are possible over integer types")
+		return TypeVoidReference
+	}
+	if !compareType(*startType, *endType) {
+		hint := fmt.Sprintf("Expected end of type %s", startType.TypeName)
+		c.nodeError(node.End, codeTypeMismatch, "Unexpected type").Hint(castHint(node.End, endType, startType, hint))
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set range type in node
+	node.Type = startType.TypeName
+	return startType
+}
+
 func (c *Context) VisitClosureStmt(node *ast.ClosureStmt) any {
 	block := node.Block
 
@@ -533,44 +1221,571 @@ func (c *Context) VisitClosureStmt(node *ast.ClosureStmt) any {
 }
 
 func (c *Context) VisitBlockStmt(node *ast.BlockStmt) any {
+	terminated, reported := false, false
 	for _, n := range node.Nodes {
+		if terminated && !reported {
+			// Only the first unreachable statement is reported
+			c.Diagnostics.Warning(codeUnreachable, "Unreachable code", c.span(n)).Hint("Remove the statement or the return, break or continue before it")
+			reported = true
+		}
 		_ = n.Visit(c)
+		terminated = terminated || terminates(n)
 	}
 	return TypeVoidReference
 }
 
 func (c *Context) VisitUnaryExpr(node *ast.UnaryExpr) any {
-	exprType := node.Expression.Visit(c).(staticDeclaration).Static()
+	return c.unary(node, TypeNoReference)
+}
+
+// unary checks a unary expression, number literals take the expected type like in binary expressions
+func (c *Context) unary(node *ast.UnaryExpr, expect *staticType) *staticType {
+	if literal, ok := node.Expression.(*ast.IntegerLitExpr); ok && node.Operator.Id == scanner.Minus {
+		exprType := c.integerLiteral(literal, true, expect)
+		node.Type = exprType.TypeName
+		return exprType
+	}
+
+	if node.Operator.Id == scanner.Bang {
+		expect = TypeNoReference
+	}
+	exprType := c.visitValue(node.Expression, expect)
 	switch node.Operator.Id {
 	case scanner.Bang:
 		if compareType(*exprType, *TypeBoolReference) {
-			c.nodeError(node, "Unary operation possible on type bool")
+			c.nodeError(node, codeInvalidOperation, "Unary operation possible on type bool")
 		}
 
 		break
 	case scanner.Plus, scanner.Minus:
-		if !compareType(*exprType, *TypeIntReference) && !compareType(*exprType, *TypeFloatReference) {
-			c.nodeError(node, "Unary operation possible on types int and float")
+		if !exprType.isNumber() {
+			c.nodeError(node, codeInvalidOperation, "Unary operation possible on numeric types")
+		} else if node.Operator.Id == scanner.Minus && exprType.isUnsigned() {
+			c.nodeError(node, codeInvalidOperation, fmt.Sprintf("Cannot negate unsigned type %s", exprType.TypeName)).Hint("Convert the value to a signed type first")
 		}
 
 		break
+	case scanner.Tilde:
+		if !exprType.isInteger() {
+			c.nodeError(node, codeInvalidOperation, "Unary operation possible on integer types").Hint("~ inverts the bits of an integer")
+		}
 	}
+	node.Type = exprType.TypeName
 	return exprType
 }
 
 func (c *Context) VisitAssignExpr(node *ast.AssignExpr) any {
 	defName := node.Name.Lexeme
-	defValue := node.Value
-	c.define(defName, node, defValue)
-	return defValue.Visit(c)
+
+	if node.Operator.Id != scanner.Equals {
+		return c.update(defName, node)
+	}
+
+	return c.define(defName, node, node.Value)
 }
 
 func (c *Context) VisitExprStmt(node *ast.ExprStmt) any {
+	if match, ok := node.Expression.(*ast.MatchExpr); ok {
+		_ = c.match(match, true, TypeNoReference)
+		return TypeVoidReference
+	}
 	_ = node.Expression.Visit(c)
 	return TypeVoidReference
 }
 
 func (c *Context) VisitErrNode(node *ast.ErrNode) any {
-	c.nodeError(node, fmt.Sprintf("Error node detected. %s", node.Message))
+	diagnostic := c.nodeError(node, codeErrNode, fmt.Sprintf("Error node detected. %s", node.Message))
+	if len(node.Hint) > 0 {
+		diagnostic.Hint(node.Hint)
+	}
+	return TypeVoidReference
+}
+
+func (c *Context) VisitStructDecl(node *ast.StructDecl) any {
+	fieldNames := make([]string, 0)
+	fieldTypes := make([]*staticType, 0)
+
+	for i, fieldName := range node.FieldName {
+		for j, prev := range fieldNames {
+			if prev == fieldName {
+				c.Diagnostics.Error(codeRedeclared, fmt.Sprintf("Field %s already declared", fieldName), node.FieldToken[i].Span(c.File)).
+					Label(node.FieldToken[j].Span(c.File), "Field is declared here")
+				return TypeVoidReference
+			}
+		}
+
+		// Lookup before declaring the struct, as recursive structs have no size
+		typeSpan := node.FieldTypeToken[i].Span(c.File)
+		fieldType, ok := c.lookupType(typeSpan, node.FieldType[i])
+		if !ok {
+			return TypeVoidReference
+		}
+		if compareType(*fieldType, *TypeNoReference) || compareType(*fieldType, *TypeVoidReference) {
+			c.Diagnostics.Error(codeTypeMismatch, fmt.Sprintf("Invalid type %s of field %s", fieldType.TypeName, fieldName), typeSpan)
+			return TypeVoidReference
+		}
+
+		fieldNames = append(fieldNames, fieldName)
+		fieldTypes = append(fieldTypes, fieldType)
+	}
+
+	decl := &staticType{DeclaredAt: node, TypeName: node.Identifier, FieldNames: fieldNames, FieldTypes: fieldTypes}
+	c.declare(decl, node)
 	return TypeVoidReference
 }
+
+func (c *Context) VisitStructLitExpr(node *ast.StructLitExpr) any {
+	structType, ok := c.lookupType(c.span(node), node.Identifier)
+	if !ok {
+		return TypeVoidReference
+	}
+
+	if !structType.isStruct() {
+		c.comparativeError(node, codeTypeMismatch, fmt.Sprintf("Type %s is not a struct", structType.TypeName), structType.Node(), "Declared here")
+		return TypeVoidReference
+	}
+
+	initialized := make(map[string]bool)
+
+	for i, field := range node.Fields {
+		fieldName := field.Lexeme
+
+		fieldType, ok := structType.field(fieldName)
+		if !ok {
+			c.Diagnostics.Error(codeUnknownField, fmt.Sprintf("Struct %s has no field %s", structType.TypeName, fieldName), field.Span(c.File)).Label(c.span(structType.Node()), "Declared here")
+			return TypeVoidReference
+		}
+
+		if initialized[fieldName] {
+			c.Diagnostics.Error(codeRedeclared, fmt.Sprintf("Field %s already initialized", fieldName), field.Span(c.File))
+			return TypeVoidReference
+		}
+		initialized[fieldName] = true
+
+		valueType := c.visitValue(node.Values[i], fieldType)
+		if !compareType(*valueType, *fieldType) {
+			c.nodeError(node.Values[i], codeTypeMismatch, "Unexpected type").Hint(castHint(node.Values[i], valueType, fieldType, fmt.Sprintf("Field %s expects value of type %s", fieldName, fieldType.TypeName)))
+			return TypeVoidReference
+		}
+	}
+
+	for _, fieldName := range structType.FieldNames {
+		if !initialized[fieldName] {
+			c.comparativeError(node, codeUndefined, fmt.Sprintf("Missing field %s in struct literal", fieldName), structType.Node(), "Declared here")
+			return TypeVoidReference
+		}
+	}
+
+	return structType
+}
+
+// fieldType resolves the type of the field name of the struct value expression
+func (c *Context) fieldType(expression ast.Node, name scanner.Token) *staticType {
+	exprType := expression.Visit(c).(staticDeclaration).Static()
+	if compareType(*exprType, *TypeVoidReference) {
+		// Already reported
+		return TypeVoidReference
+	}
+
+	if !exprType.isStruct() {
+		c.Diagnostics.Error(codeInvalidOperation, fmt.Sprintf("Field access on type %s", exprType.TypeName), name.Span(c.File)).Hint("Fields can only be accessed on structs")
+		return TypeVoidReference
+	}
+
+	fieldType, ok := exprType.field(name.Lexeme)
+	if !ok {
+		c.Diagnostics.Error(codeUnknownField, fmt.Sprintf("Struct %s has no field %s", exprType.TypeName, name.Lexeme), name.Span(c.File)).Label(c.span(exprType.Node()), "Declared here")
+		return TypeVoidReference
+	}
+
+	return fieldType
+}
+
+func (c *Context) VisitGetExpr(node *ast.GetExpr) any {
+	return c.fieldType(node.Expression, node.Name)
+}
+
+func (c *Context) VisitSetExpr(node *ast.SetExpr) any {
+	// Only fields of variables can be assigned, e.g. a.b.c = 1
+	if !assignable(node.Expression) {
+		c.nodeError(node, codeInvalidOperation, "Cannot assign to field of temporary value").Hint("Store the value in a variable first")
+		return TypeVoidReference
+	}
+
+	fieldType := c.fieldType(node.Expression, node.Name)
+	if compareType(*fieldType, *TypeVoidReference) {
+		return TypeVoidReference
+	}
+
+	if node.Operator.Id != scanner.Equals && !c.compound(node, node.Operator, fieldType) {
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set field type in node
+	node.Type = fieldType.TypeName
+
+	valueType := c.visitValue(node.Value, fieldType)
+	if !compareType(*valueType, *fieldType) {
+		c.nodeError(node.Value, codeTypeMismatch, "Unexpected type").Hint(castHint(node.Value, valueType, fieldType, fmt.Sprintf("Field %s expects value of type %s", node.Name.Lexeme, fieldType.TypeName)))
+		return TypeVoidReference
+	}
+
+	return fieldType
+}
+
+// genericBuiltin checks calls of builtins that accept collections of any element type
+func (c *Context) genericBuiltin(fn *function, node *ast.CallExpr) *staticType {
+	collection := node.Arguments[0]
+	collectionType := collection.Visit(c).(staticDeclaration).Static()
+	if compareType(*collectionType, *TypeVoidReference) {
+		// Already reported
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set operand type in node
+	node.Type = collectionType.TypeName
+
+	if fn == BuiltinLength {
+		if collectionType.Element == nil && !compareType(*collectionType, *TypeStringReference) {
+			c.nodeError(collection, codeTypeMismatch, fmt.Sprintf("Cannot take length of type %s", collectionType.TypeName)).Hint("len accepts strings, arrays and slices")
+			return TypeVoidReference
+		}
+		return TypeIntReference
+	}
+
+	if !collectionType.isSlice() {
+		c.nodeError(collection, codeTypeMismatch, fmt.Sprintf("Cannot append to type %s", collectionType.TypeName)).Hint("append accepts slices like []int")
+		return TypeVoidReference
+	}
+
+	valueType := c.visitValue(node.Arguments[1], collectionType.Element)
+	if !compareType(*valueType, *collectionType.Element) {
+		c.nodeError(node.Arguments[1], codeTypeMismatch, "Unexpected type").Hint(castHint(node.Arguments[1], valueType, collectionType.Element, fmt.Sprintf("Expected element of type %s", collectionType.Element.TypeName)))
+		return TypeVoidReference
+	}
+
+	return collectionType
+}
+
+func (c *Context) VisitArrayLitExpr(node *ast.ArrayLitExpr) any {
+	return c.arrayLiteral(node, TypeNoReference)
+}
+
+// arrayLiteral types the literal as expect if it is a slice or array type, otherwise as fixed array of its elements
+func (c *Context) arrayLiteral(node *ast.ArrayLitExpr, expect *staticType) *staticType {
+	var element *staticType
+	if expect != nil && expect.Element != nil {
+		element = expect.Element
+	}
+
+	for i, value := range node.Values {
+		valueType := c.visitValue(value, element)
+		if compareType(*valueType, *TypeVoidReference) {
+			// Already reported
+			return TypeVoidReference
+		}
+
+		if element == nil {
+			element = valueType
+		}
+
+		if !compareType(*valueType, *element) {
+			c.nodeError(value, codeTypeMismatch, "Unexpected type").Hint(castHint(value, valueType, element, fmt.Sprintf("Expected element of type %s at position %d", element.TypeName, i)))
+			return TypeVoidReference
+		}
+	}
+
+	if element == nil {
+		c.nodeError(node, codeInferType, "Cannot infer type of empty array literal").Hint("Declare the type like: let values: []int = [];")
+		return TypeVoidReference
+	}
+
+	literalType := &staticType{DeclaredAt: initialNode, TypeName: ast.ArrayTypeName(element.TypeName, len(node.Values)), Element: element, Length: len(node.Values)}
+	if expect != nil && expect.isSlice() {
+		literalType = expect
+	}
+
+	// CONTEXT: Set type in node
+	node.Type = literalType.TypeName
+
+	return literalType
+}
+
+// elementType checks indexing the collection expression with index
+func (c *Context) elementType(expression ast.Node, index ast.Node) *staticType {
+	collectionType := expression.Visit(c).(staticDeclaration).Static()
+	if compareType(*collectionType, *TypeVoidReference) {
+		// Already reported
+		return TypeVoidReference
+	}
+
+	if collectionType.Element == nil {
+		c.nodeError(expression, codeInvalidOperation, fmt.Sprintf("Index on type %s", collectionType.TypeName)).Hint("Only arrays and slices can be indexed")
+		return TypeVoidReference
+	}
+
+	indexType := index.Visit(c).(staticDeclaration).Static()
+	if !indexType.isInteger() {
+		c.nodeError(index, codeTypeMismatch, fmt.Sprintf("Invalid index type %s", indexType.TypeName)).Hint("Indices are of integer types like int or usize")
+		return TypeVoidReference
+	}
+
+	return collectionType
+}
+
+func (c *Context) VisitIndexExpr(node *ast.IndexExpr) any {
+	collectionType := c.elementType(node.Expression, node.Index)
+	if collectionType.Element == nil {
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set operand type in node
+	node.Type = collectionType.TypeName
+
+	return collectionType.Element
+}
+
+func (c *Context) VisitSetIndexExpr(node *ast.SetIndexExpr) any {
+	// Only elements of variables can be assigned, e.g. a.b[1] = 1
+	if !assignable(node.Expression) {
+		c.nodeError(node, codeInvalidOperation, "Cannot assign to element of temporary value").Hint("Store the value in a variable first")
+		return TypeVoidReference
+	}
+
+	collectionType := c.elementType(node.Expression, node.Index)
+	if collectionType.Element == nil {
+		return TypeVoidReference
+	}
+	element := collectionType.Element
+
+	// CONTEXT: Set operand type in node
+	node.Type = collectionType.TypeName
+
+	if node.Operator.Id != scanner.Equals && !c.compound(node, node.Operator, element) {
+		return TypeVoidReference
+	}
+
+	valueType := c.visitValue(node.Value, element)
+	if !compareType(*valueType, *element) {
+		c.nodeError(node.Value, codeTypeMismatch, "Unexpected type").Hint(castHint(node.Value, valueType, element, fmt.Sprintf("Expected element of type %s", element.TypeName)))
+		return TypeVoidReference
+	}
+
+	return element
+}
+
+func (c *Context) VisitCastExpr(node *ast.CastExpr) any {
+	exprType := node.Expression.Visit(c).(staticDeclaration).Static()
+	if compareType(*exprType, *TypeVoidReference) {
+		// Already reported
+		return TypeVoidReference
+	}
+
+	targetType, ok := c.lookupType(c.span(node), node.TargetType)
+	if !ok {
+		return TypeVoidReference
+	}
+
+	if !exprType.isNumber() || !targetType.isNumber() {
+		c.nodeError(node, codeInvalidOperation, fmt.Sprintf("Cannot convert type %s to %s", exprType.TypeName, targetType.TypeName)).Hint("Conversions are possible between numeric types like int, u8 and f32")
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set operand type in node
+	node.Type = exprType.TypeName
+
+	return targetType
+}
+
+func (c *Context) VisitMatchExpr(node *ast.MatchExpr) any {
+	return c.match(node, false, TypeNoReference)
+}
+
+// match checks a match over integers, bools or strings. Statements may have block arms and only need to cover every
+// value of bools, while the arms of values are expressions of a common type and cover every value.
+func (c *Context) match(node *ast.MatchExpr, statement bool, expect *staticType) *staticType {
+	valueType := c.visitValue(node.Value, nil)
+	if compareType(*valueType, *TypeVoidReference) {
+		// Already reported
+		return TypeVoidReference
+	}
+	if !valueType.isInteger() && !compareType(*valueType, *TypeBoolReference) && !compareType(*valueType, *TypeStringReference) {
+		c.nodeError(node.Value, codeTypeMismatch, fmt.Sprintf("Cannot match type %s", valueType.TypeName)).Hint("Match is possible on integers, bools and strings")
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set matched type in node
+	node.Type = valueType.TypeName
+
+	if !c.patterns(node, valueType) {
+		return TypeVoidReference
+	}
+
+	exhaustive := exhaustive(node)
+	if !exhaustive && (!statement || compareType(*valueType, *TypeBoolReference)) {
+		hint := "Add a _ arm for the remaining values"
+		if compareType(*valueType, *TypeBoolReference) {
+			hint = "Add arms for true and false, or a _ arm"
+		}
+		c.nodeError(node, codeNotExhaustive, "Match is not exhaustive").Hint(hint)
+	}
+
+	// Every arm starts from the state after the value
+	before := c.Unassigned.clone()
+	states := make([]assignments, len(node.Arms))
+	resultType := TypeVoidReference
+	if statement {
+		// Statements with arms of a common type have a value too, which the REPL prints
+		var armsType *staticType
+		value := exhaustive
+		for i, arm := range node.Arms {
+			c.Unassigned = before.clone()
+			if arm.GetId() == ast.ClosureId {
+				_ = arm.Visit(c)
+				value = false
+			} else {
+				armType := c.visitValue(arm, nil)
+				if compareType(*armType, *TypeVoidReference) || compareType(*armType, *TypeNoReference) {
+					value = false
+				} else if armsType == nil {
+					armsType = armType
+				} else {
+					common := commonType(*armsType, *armType)
+					armsType = &common
+				}
+			}
+			states[i] = c.Unassigned
+		}
+
+		if value && armsType != nil && !compareType(*armsType, *TypeNoReference) {
+			// CONTEXT: Set result type in node
+			node.ResultType = armsType.TypeName
+		}
+	} else {
+		resultType = c.arms(node, expect, before, states)
+	}
+
+	after := assignments(nil)
+	if !exhaustive {
+		after = before
+	}
+	for i, arm := range node.Arms {
+		if terminates(arm) {
+			continue
+		}
+		if after == nil {
+			after = states[i]
+		} else {
+			after = join(after, states[i])
+		}
+	}
+	if after == nil {
+		// No arm completes, the following statements are not reached
+		after = before
+	}
+	c.Unassigned = after
+
+	return resultType
+}
+
+// patterns checks that the patterns of node are of the matched type and that no value is matched twice
+func (c *Context) patterns(node *ast.MatchExpr, valueType *staticType) bool {
+	matched := make(map[string]ast.Node)
+	var wildcard ast.Node
+	for i, alternatives := range node.Patterns {
+		if wildcard != nil {
+			c.Diagnostics.Warning(codeUnreachable, "Unreachable match arm", c.span(node.Arms[i])).Hint("Remove the arm or the _ arm before it")
+		}
+		if len(alternatives) == 0 && wildcard == nil {
+			wildcard = node.Arms[i]
+		}
+
+		for _, pattern := range alternatives {
+			patternType := c.visitValue(pattern, valueType)
+			if !compareType(*patternType, *valueType) {
+				c.nodeError(pattern, codeTypeMismatch, "Unexpected pattern type").Hint(fmt.Sprintf("Expected pattern of type %s", valueType.TypeName))
+				return false
+			}
+
+			key := patternValue(pattern)
+			if first, ok := matched[key]; ok {
+				c.comparativeError(pattern, codeDuplicatePattern, "Duplicate pattern", first, "Value is already matched here")
+				continue
+			}
+			matched[key] = pattern
+		}
+	}
+	return true
+}
+
+// patternValue returns the value of a literal pattern in a form that is equal for equal values
+func patternValue(pattern ast.Node) string {
+	switch p := pattern.(type) {
+	case *ast.IntegerLitExpr:
+		return p.Value
+	case *ast.UnaryExpr:
+		value, ok := new(big.Int).SetString(p.Expression.(*ast.IntegerLitExpr).Value, 10)
+		if !ok {
+			return p.String()
+		}
+		return value.Neg(value).String()
+	case *ast.BooleanLitExpr:
+		return p.Value
+	case *ast.StringLitExpr:
+		return p.Value
+	}
+	return pattern.String()
+}
+
+// arms checks the arms of a match value, they have a common type. Typed arms are checked first, so number literals in
+// the other arms take their type. The assignments at the end of each arm are stored in states.
+func (c *Context) arms(node *ast.MatchExpr, expect *staticType, before assignments, states []assignments) *staticType {
+	var resultType *staticType
+	var first ast.Node
+	for _, literals := range []bool{false, true} {
+		for i, arm := range node.Arms {
+			if untyped(arm) != literals {
+				continue
+			}
+			if arm.GetId() == ast.ClosureId {
+				c.nodeError(arm, codeInvalidOperation, "Block arm in match value").Hint("Use an expression like: 1 => value, or match as a statement")
+				return TypeVoidReference
+			}
+
+			c.Unassigned = before.clone()
+			armExpect := expect
+			if resultType != nil {
+				armExpect = resultType
+			}
+			armType := c.visitValue(arm, armExpect)
+			states[i] = c.Unassigned
+			if compareType(*armType, *TypeVoidReference) {
+				// Already reported
+				return TypeVoidReference
+			}
+
+			if resultType == nil {
+				resultType, first = armType, arm
+				continue
+			}
+			common := commonType(*resultType, *armType)
+			if compareType(common, *TypeNoReference) {
+				diagnostic := c.comparativeError(arm, codeTypeMismatch, fmt.Sprintf("Match arm of type %s", armType.TypeName), first, fmt.Sprintf("Expected arms of type %s", resultType.TypeName))
+				if hint := castHint(arm, armType, resultType, ""); len(hint) > 0 {
+					diagnostic.Hint(hint)
+				}
+				return TypeVoidReference
+			}
+			resultType = &common
+		}
+	}
+	if resultType == nil {
+		c.nodeError(node, codeInferType, "Cannot infer type of match without arms").Hint("Add arms like: 1 => value")
+		return TypeVoidReference
+	}
+
+	// CONTEXT: Set result type in node
+	node.ResultType = resultType.TypeName
+
+	return resultType
+}
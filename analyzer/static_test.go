@@ -0,0 +1,59 @@
+package analyzer
+
+import (
+	"breeze/common"
+	"breeze/parser"
+	"breeze/scanner"
+	"testing"
+)
+
+func TestAnalyzeDiagnostics(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		severity common.Severity
+		code     string
+		line     int
+		column   int
+	}{
+		{"break outside of loop", "break;", common.SeverityError, codeOutsideLoop, 1, 1},
+		{"continue in function", "fn f() {\n    continue;\n}", common.SeverityError, codeOutsideLoop, 2, 5},
+		{"loop in function", "while true {\n}\nfn f() {\n    break;\n}", common.SeverityError, codeOutsideLoop, 4, 5},
+		{"while condition", "while 1 {\n}", common.SeverityError, codeTypeMismatch, 1, 7},
+		{"missing return", "fn f(bool c) -> int {\n    if c {\n        return 1;\n    }\n}", common.SeverityError, codeInvalidReturn, 5, 1},
+		{"missing return after loop", "fn f(bool c) -> int {\n    while c {\n        return 1;\n    }\n}", common.SeverityError, codeInvalidReturn, 5, 1},
+		{"unreachable after return", "fn f() -> int {\n    return 1;\n    debug 2;\n}", common.SeverityWarning, codeUnreachable, 3, 5},
+		{"unreachable after break", "while true {\n    break;\n    debug 1;\n}", common.SeverityWarning, codeUnreachable, 3, 5},
+		{"assigned in one branch", "fn f(bool c) {\n    let x: int;\n    if c {\n        x = 1;\n    }\n    debug x;\n}", common.SeverityError, codeUndefined, 6, 11},
+		{"updated before assignment", "fn f() -> int {\n    let x: int;\n    x += 1;\n    return x;\n}", common.SeverityError, codeUndefined, 3, 5},
+		{"assigned in loop", "fn f() {\n    let x: int;\n    while false {\n        x = 1;\n    }\n    debug x;\n}", common.SeverityError, codeUndefined, 6, 11},
+		{"integer literal out of range", "let x: u8 = 300;", common.SeverityError, codeOutOfRange, 1, 13},
+		{"undeclared field type", "struct Line { a: Point }", common.SeverityError, codeUndeclared, 1, 18},
+		{"undeclared field element type", "struct Path { points: [[]Point; 2] }", common.SeverityError, codeUndeclared, 1, 26},
+		{"duplicate field", "struct Point { x: int, x: int }", common.SeverityError, codeRedeclared, 1, 24},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := common.SourceFile{Path: "test.bz"}
+			tokens, diagnostics := scanner.Scan(&file, test.source)
+			nodes, parsed := parser.ParseTokens(file, tokens)
+			diagnostics.Merge(parsed)
+			if !diagnostics.Empty() {
+				t.Fatalf("unexpected diagnostic: %s", diagnostics.Diagnostics[0].Message)
+			}
+
+			diagnostics = Analyze(file, nodes)
+			if len(diagnostics.Diagnostics) != 1 {
+				t.Fatalf("expected 1 diagnostic, got %d", len(diagnostics.Diagnostics))
+			}
+
+			diagnostic := diagnostics.Diagnostics[0]
+			position := diagnostic.Span.Position
+			if diagnostic.Severity != test.severity || diagnostic.Code != test.code || position.Line != test.line || position.Column != test.column {
+				t.Errorf("expected %s %s at %d:%d, got %s %s at %d:%d (%s)", test.severity, test.code, test.line, test.column,
+					diagnostic.Severity, diagnostic.Code, position.Line, position.Column, diagnostic.Message)
+			}
+		})
+	}
+}
@@ -28,6 +28,18 @@ const (
 	BlockId
 	FloatingLitId
 	BooleanLitId
+	StringLitId
+	StructId
+	StructLitId
+	GetId
+	SetId
+	ArrayLitId
+	IndexId
+	SetIndexId
+	CastId
+	ForId
+	ForInId
+	MatchId
 )
 
 type NodeType uint8
@@ -68,6 +80,18 @@ type Visitor interface {
 	VisitBlockStmt(node *BlockStmt) any
 	VisitFloatingLitExpr(node *FloatingLitExpr) any
 	VisitBooleanLitExpr(node *BooleanLitExpr) any
+	VisitStringLitExpr(node *StringLitExpr) any
+	VisitStructDecl(node *StructDecl) any
+	VisitStructLitExpr(node *StructLitExpr) any
+	VisitGetExpr(node *GetExpr) any
+	VisitSetExpr(node *SetExpr) any
+	VisitArrayLitExpr(node *ArrayLitExpr) any
+	VisitIndexExpr(node *IndexExpr) any
+	VisitSetIndexExpr(node *SetIndexExpr) any
+	VisitCastExpr(node *CastExpr) any
+	VisitForStmt(node *ForStmt) any
+	VisitForInStmt(node *ForInStmt) any
+	VisitMatchExpr(node *MatchExpr) any
 }
 
 type ConditionalStmt struct {
@@ -129,6 +153,7 @@ type ClosureStmt struct {
 	Node
 	Token scanner.Token
 	Block Node
+	End   scanner.Token
 }
 
 func (node *ClosureStmt) GetType() NodeType {
@@ -140,7 +165,7 @@ func (node *ClosureStmt) GetId() NodeId {
 }
 
 func (node *ClosureStmt) String() string {
-	return "(ClosureStmt Block=" + fmt.Sprintf("%s", node.Block) + ")"
+	return "(ClosureStmt Block=" + fmt.Sprintf("%s", node.Block) + " End=" + fmt.Sprintf("%s", node.End) + ")"
 }
 
 func (node *ClosureStmt) GetToken() scanner.Token {
@@ -182,6 +207,7 @@ type AssignExpr struct {
 	Name     scanner.Token
 	Value    Node
 	Operator scanner.Token
+	Type     string
 }
 
 func (node *AssignExpr) GetType() NodeType {
@@ -193,7 +219,7 @@ func (node *AssignExpr) GetId() NodeId {
 }
 
 func (node *AssignExpr) String() string {
-	return "(AssignExpr Name=" + fmt.Sprintf("%s", node.Name) + " Value=" + fmt.Sprintf("%s", node.Value) + " Operator=" + fmt.Sprintf("%s", node.Operator) + ")"
+	return "(AssignExpr Name=" + fmt.Sprintf("%s", node.Name) + " Value=" + fmt.Sprintf("%s", node.Value) + " Operator=" + fmt.Sprintf("%s", node.Operator) + " Type=" + string(node.Type) + ")"
 }
 
 func (node *AssignExpr) GetToken() scanner.Token {
@@ -263,6 +289,7 @@ type BinaryExpr struct {
 	Right    Node
 	Left     Node
 	Operator scanner.Token
+	Type     string
 }
 
 func (node *BinaryExpr) GetType() NodeType {
@@ -274,7 +301,7 @@ func (node *BinaryExpr) GetId() NodeId {
 }
 
 func (node *BinaryExpr) String() string {
-	return "(BinaryExpr Right=" + fmt.Sprintf("%s", node.Right) + " Left=" + fmt.Sprintf("%s", node.Left) + " Operator=" + fmt.Sprintf("%s", node.Operator) + ")"
+	return "(BinaryExpr Right=" + fmt.Sprintf("%s", node.Right) + " Left=" + fmt.Sprintf("%s", node.Left) + " Operator=" + fmt.Sprintf("%s", node.Operator) + " Type=" + string(node.Type) + ")"
 }
 
 func (node *BinaryExpr) GetToken() scanner.Token {
@@ -289,6 +316,7 @@ type UnaryExpr struct {
 	Node
 	Operator   scanner.Token
 	Expression Node
+	Type       string
 }
 
 func (node *UnaryExpr) GetType() NodeType {
@@ -300,7 +328,7 @@ func (node *UnaryExpr) GetId() NodeId {
 }
 
 func (node *UnaryExpr) String() string {
-	return "(UnaryExpr Operator=" + fmt.Sprintf("%s", node.Operator) + " Expression=" + fmt.Sprintf("%s", node.Expression) + ")"
+	return "(UnaryExpr Operator=" + fmt.Sprintf("%s", node.Operator) + " Expression=" + fmt.Sprintf("%s", node.Expression) + " Type=" + string(node.Type) + ")"
 }
 
 func (node *UnaryExpr) GetToken() scanner.Token {
@@ -362,6 +390,7 @@ type CallExpr struct {
 	Token      scanner.Token
 	Arguments  []Node
 	Expression Node
+	Type       string
 }
 
 func (node *CallExpr) GetType() NodeType {
@@ -381,7 +410,7 @@ func (node *CallExpr) String() string {
 		}
 	}
 	str_Arguments += "}"
-	return "(CallExpr Arguments=" + str_Arguments + " Expression=" + fmt.Sprintf("%s", node.Expression) + ")"
+	return "(CallExpr Arguments=" + str_Arguments + " Expression=" + fmt.Sprintf("%s", node.Expression) + " Type=" + string(node.Type) + ")"
 }
 
 func (node *CallExpr) GetToken() scanner.Token {
@@ -396,6 +425,7 @@ type DebugStmt struct {
 	Node
 	Token      scanner.Token
 	Expression Node
+	Type       string
 }
 
 func (node *DebugStmt) GetType() NodeType {
@@ -407,7 +437,7 @@ func (node *DebugStmt) GetId() NodeId {
 }
 
 func (node *DebugStmt) String() string {
-	return "(DebugStmt Expression=" + fmt.Sprintf("%s", node.Expression) + ")"
+	return "(DebugStmt Expression=" + fmt.Sprintf("%s", node.Expression) + " Type=" + string(node.Type) + ")"
 }
 
 func (node *DebugStmt) GetToken() scanner.Token {
@@ -524,6 +554,7 @@ type IntegerLitExpr struct {
 	Node
 	Token scanner.Token
 	Value string
+	Type  string
 }
 
 func (node *IntegerLitExpr) GetType() NodeType {
@@ -535,7 +566,7 @@ func (node *IntegerLitExpr) GetId() NodeId {
 }
 
 func (node *IntegerLitExpr) String() string {
-	return "(IntegerLitExpr Value=" + string(node.Value) + ")"
+	return "(IntegerLitExpr Value=" + string(node.Value) + " Type=" + string(node.Type) + ")"
 }
 
 func (node *IntegerLitExpr) GetToken() scanner.Token {
@@ -584,6 +615,7 @@ type FloatingLitExpr struct {
 	Node
 	Token scanner.Token
 	Value string
+	Type  string
 }
 
 func (node *FloatingLitExpr) GetType() NodeType {
@@ -595,7 +627,7 @@ func (node *FloatingLitExpr) GetId() NodeId {
 }
 
 func (node *FloatingLitExpr) String() string {
-	return "(FloatingLitExpr Value=" + string(node.Value) + ")"
+	return "(FloatingLitExpr Value=" + string(node.Value) + " Type=" + string(node.Type) + ")"
 }
 
 func (node *FloatingLitExpr) GetToken() scanner.Token {
@@ -631,3 +663,415 @@ func (node *BooleanLitExpr) GetToken() scanner.Token {
 func (node *BooleanLitExpr) Visit(visitor Visitor) any {
 	return visitor.VisitBooleanLitExpr(node)
 }
+
+type StringLitExpr struct {
+	Node
+	Token scanner.Token
+	Value string
+}
+
+func (node *StringLitExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *StringLitExpr) GetId() NodeId {
+	return StringLitId
+}
+
+func (node *StringLitExpr) String() string {
+	return "(StringLitExpr Value=" + string(node.Value) + ")"
+}
+
+func (node *StringLitExpr) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *StringLitExpr) Visit(visitor Visitor) any {
+	return visitor.VisitStringLitExpr(node)
+}
+
+type StructDecl struct {
+	Node
+	Token          scanner.Token
+	Identifier     string
+	FieldType      []string
+	FieldName      []string
+	FieldToken     []scanner.Token
+	FieldTypeToken []scanner.Token
+}
+
+func (node *StructDecl) GetType() NodeType {
+	return Decl
+}
+
+func (node *StructDecl) GetId() NodeId {
+	return StructId
+}
+
+func (node *StructDecl) String() string {
+	str_FieldType := "{"
+	for i, n := range node.FieldType {
+		str_FieldType += fmt.Sprintf("%s", n)
+		if i <= len(node.FieldType)-1 {
+			str_FieldType += ", "
+		}
+	}
+	str_FieldType += "}"
+	str_FieldName := "{"
+	for i, n := range node.FieldName {
+		str_FieldName += fmt.Sprintf("%s", n)
+		if i <= len(node.FieldName)-1 {
+			str_FieldName += ", "
+		}
+	}
+	str_FieldName += "}"
+	str_FieldToken := "{"
+	for i, n := range node.FieldToken {
+		str_FieldToken += fmt.Sprintf("%s", n)
+		if i <= len(node.FieldToken)-1 {
+			str_FieldToken += ", "
+		}
+	}
+	str_FieldToken += "}"
+	str_FieldTypeToken := "{"
+	for i, n := range node.FieldTypeToken {
+		str_FieldTypeToken += fmt.Sprintf("%s", n)
+		if i <= len(node.FieldTypeToken)-1 {
+			str_FieldTypeToken += ", "
+		}
+	}
+	str_FieldTypeToken += "}"
+	return "(StructDecl Identifier=" + string(node.Identifier) + " FieldType=" + str_FieldType + " FieldName=" + str_FieldName + " FieldToken=" + str_FieldToken + " FieldTypeToken=" + str_FieldTypeToken + ")"
+}
+
+func (node *StructDecl) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *StructDecl) Visit(visitor Visitor) any {
+	return visitor.VisitStructDecl(node)
+}
+
+type StructLitExpr struct {
+	Node
+	Token      scanner.Token
+	Identifier string
+	Fields     []scanner.Token
+	Values     []Node
+}
+
+func (node *StructLitExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *StructLitExpr) GetId() NodeId {
+	return StructLitId
+}
+
+func (node *StructLitExpr) String() string {
+	str_Fields := "{"
+	for i, n := range node.Fields {
+		str_Fields += fmt.Sprintf("%s", n)
+		if i <= len(node.Fields)-1 {
+			str_Fields += ", "
+		}
+	}
+	str_Fields += "}"
+	str_Values := "{"
+	for i, n := range node.Values {
+		str_Values += fmt.Sprintf("%s", n)
+		if i <= len(node.Values)-1 {
+			str_Values += ", "
+		}
+	}
+	str_Values += "}"
+	return "(StructLitExpr Identifier=" + string(node.Identifier) + " Fields=" + str_Fields + " Values=" + str_Values + ")"
+}
+
+func (node *StructLitExpr) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *StructLitExpr) Visit(visitor Visitor) any {
+	return visitor.VisitStructLitExpr(node)
+}
+
+type GetExpr struct {
+	Node
+	Name       scanner.Token
+	Expression Node
+}
+
+func (node *GetExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *GetExpr) GetId() NodeId {
+	return GetId
+}
+
+func (node *GetExpr) String() string {
+	return "(GetExpr Name=" + fmt.Sprintf("%s", node.Name) + " Expression=" + fmt.Sprintf("%s", node.Expression) + ")"
+}
+
+func (node *GetExpr) GetToken() scanner.Token {
+	return node.Name
+}
+
+func (node *GetExpr) Visit(visitor Visitor) any {
+	return visitor.VisitGetExpr(node)
+}
+
+type SetExpr struct {
+	Node
+	Name       scanner.Token
+	Operator   scanner.Token
+	Expression Node
+	Value      Node
+	Type       string
+}
+
+func (node *SetExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *SetExpr) GetId() NodeId {
+	return SetId
+}
+
+func (node *SetExpr) String() string {
+	return "(SetExpr Name=" + fmt.Sprintf("%s", node.Name) + " Operator=" + fmt.Sprintf("%s", node.Operator) + " Expression=" + fmt.Sprintf("%s", node.Expression) + " Value=" + fmt.Sprintf("%s", node.Value) + " Type=" + string(node.Type) + ")"
+}
+
+func (node *SetExpr) GetToken() scanner.Token {
+	return node.Name
+}
+
+func (node *SetExpr) Visit(visitor Visitor) any {
+	return visitor.VisitSetExpr(node)
+}
+
+type ArrayLitExpr struct {
+	Node
+	Token  scanner.Token
+	Values []Node
+	Type   string
+}
+
+func (node *ArrayLitExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *ArrayLitExpr) GetId() NodeId {
+	return ArrayLitId
+}
+
+func (node *ArrayLitExpr) String() string {
+	str_Values := "{"
+	for i, n := range node.Values {
+		str_Values += fmt.Sprintf("%s", n)
+		if i <= len(node.Values)-1 {
+			str_Values += ", "
+		}
+	}
+	str_Values += "}"
+	return "(ArrayLitExpr Values=" + str_Values + " Type=" + string(node.Type) + ")"
+}
+
+func (node *ArrayLitExpr) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *ArrayLitExpr) Visit(visitor Visitor) any {
+	return visitor.VisitArrayLitExpr(node)
+}
+
+type IndexExpr struct {
+	Node
+	Token      scanner.Token
+	Expression Node
+	Index      Node
+	Type       string
+}
+
+func (node *IndexExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *IndexExpr) GetId() NodeId {
+	return IndexId
+}
+
+func (node *IndexExpr) String() string {
+	return "(IndexExpr Expression=" + fmt.Sprintf("%s", node.Expression) + " Index=" + fmt.Sprintf("%s", node.Index) + " Type=" + string(node.Type) + ")"
+}
+
+func (node *IndexExpr) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *IndexExpr) Visit(visitor Visitor) any {
+	return visitor.VisitIndexExpr(node)
+}
+
+type SetIndexExpr struct {
+	Node
+	Operator   scanner.Token
+	Expression Node
+	Index      Node
+	Value      Node
+	Type       string
+}
+
+func (node *SetIndexExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *SetIndexExpr) GetId() NodeId {
+	return SetIndexId
+}
+
+func (node *SetIndexExpr) String() string {
+	return "(SetIndexExpr Operator=" + fmt.Sprintf("%s", node.Operator) + " Expression=" + fmt.Sprintf("%s", node.Expression) + " Index=" + fmt.Sprintf("%s", node.Index) + " Value=" + fmt.Sprintf("%s", node.Value) + " Type=" + string(node.Type) + ")"
+}
+
+func (node *SetIndexExpr) GetToken() scanner.Token {
+	return node.Operator
+}
+
+func (node *SetIndexExpr) Visit(visitor Visitor) any {
+	return visitor.VisitSetIndexExpr(node)
+}
+
+type CastExpr struct {
+	Node
+	Token      scanner.Token
+	Expression Node
+	TargetType string
+	Type       string
+}
+
+func (node *CastExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *CastExpr) GetId() NodeId {
+	return CastId
+}
+
+func (node *CastExpr) String() string {
+	return "(CastExpr Expression=" + fmt.Sprintf("%s", node.Expression) + " TargetType=" + string(node.TargetType) + " Type=" + string(node.Type) + ")"
+}
+
+func (node *CastExpr) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *CastExpr) Visit(visitor Visitor) any {
+	return visitor.VisitCastExpr(node)
+}
+
+type ForStmt struct {
+	Node
+	Token       scanner.Token
+	Initializer Node
+	Condition   Node
+	Increment   Node
+	Statement   Node
+}
+
+func (node *ForStmt) GetType() NodeType {
+	return Stmt
+}
+
+func (node *ForStmt) GetId() NodeId {
+	return ForId
+}
+
+func (node *ForStmt) String() string {
+	return "(ForStmt Initializer=" + fmt.Sprintf("%s", node.Initializer) + " Condition=" + fmt.Sprintf("%s", node.Condition) + " Increment=" + fmt.Sprintf("%s", node.Increment) + " Statement=" + fmt.Sprintf("%s", node.Statement) + ")"
+}
+
+func (node *ForStmt) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *ForStmt) Visit(visitor Visitor) any {
+	return visitor.VisitForStmt(node)
+}
+
+type ForInStmt struct {
+	Node
+	Token      scanner.Token
+	Name       scanner.Token
+	Expression Node
+	End        Node
+	Statement  Node
+	Type       string
+}
+
+func (node *ForInStmt) GetType() NodeType {
+	return Stmt
+}
+
+func (node *ForInStmt) GetId() NodeId {
+	return ForInId
+}
+
+func (node *ForInStmt) String() string {
+	return "(ForInStmt Name=" + fmt.Sprintf("%s", node.Name) + " Expression=" + fmt.Sprintf("%s", node.Expression) + " End=" + fmt.Sprintf("%s", node.End) + " Statement=" + fmt.Sprintf("%s", node.Statement) + " Type=" + string(node.Type) + ")"
+}
+
+func (node *ForInStmt) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *ForInStmt) Visit(visitor Visitor) any {
+	return visitor.VisitForInStmt(node)
+}
+
+type MatchExpr struct {
+	Node
+	Token      scanner.Token
+	Value      Node
+	Patterns   [][]Node
+	Arms       []Node
+	Type       string
+	ResultType string
+}
+
+func (node *MatchExpr) GetType() NodeType {
+	return Expr
+}
+
+func (node *MatchExpr) GetId() NodeId {
+	return MatchId
+}
+
+func (node *MatchExpr) String() string {
+	str_Patterns := "{"
+	for i, n := range node.Patterns {
+		str_Patterns += fmt.Sprintf("%s", n)
+		if i <= len(node.Patterns)-1 {
+			str_Patterns += ", "
+		}
+	}
+	str_Patterns += "}"
+	str_Arms := "{"
+	for i, n := range node.Arms {
+		str_Arms += fmt.Sprintf("%s", n)
+		if i <= len(node.Arms)-1 {
+			str_Arms += ", "
+		}
+	}
+	str_Arms += "}"
+	return "(MatchExpr Value=" + fmt.Sprintf("%s", node.Value) + " Patterns=" + str_Patterns + " Arms=" + str_Arms + " Type=" + string(node.Type) + " ResultType=" + string(node.ResultType) + ")"
+}
+
+func (node *MatchExpr) GetToken() scanner.Token {
+	return node.Token
+}
+
+func (node *MatchExpr) Visit(visitor Visitor) any {
+	return visitor.VisitMatchExpr(node)
+}
@@ -0,0 +1,120 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Types are referenced by name in the tree. Collection types are spelled like in the source:
+// fixed arrays as [int; 4] and slices as []int
+
+func ArrayTypeName(element string, length int) string {
+	return fmt.Sprintf("[%s; %d]", element, length)
+}
+
+func SliceTypeName(element string) string {
+	return "[]" + element
+}
+
+// ArrayType splits a fixed array type name into its element type and length
+func ArrayType(name string) (string, int, bool) {
+	if !strings.HasPrefix(name, "[") || strings.HasPrefix(name, "[]") || !strings.HasSuffix(name, "]") {
+		return "", 0, false
+	}
+
+	// The length always comes last, the element type may itself be an array
+	separator := strings.LastIndex(name, "; ")
+	if separator < 0 {
+		return "", 0, false
+	}
+
+	length, err := strconv.Atoi(name[separator+2 : len(name)-1])
+	if err != nil {
+		return "", 0, false
+	}
+
+	return name[1:separator], length, true
+}
+
+// SliceType returns the element type of a slice type name
+func SliceType(name string) (string, bool) {
+	if !strings.HasPrefix(name, "[]") {
+		return "", false
+	}
+	return name[2:], true
+}
+
+// NumberKind tells how the bits of a numeric type are interpreted
+type NumberKind uint8
+
+const (
+	Signed NumberKind = iota
+	Unsigned
+	Floating
+)
+
+// Number is the representation of a numeric type
+type Number struct {
+	Kind NumberKind
+	Bits int
+}
+
+// NumberTypeNames are the numeric types. int and float have the size of i64 and f64 but are distinct types.
+var NumberTypeNames = []string{"int", "float", "i8", "i16", "i32", "i64", "u8", "u16", "u32", "u64", "usize", "f32", "f64"}
+
+var numbers = map[string]Number{
+	"int":   {Signed, 64},
+	"float": {Floating, 64},
+	"i8":    {Signed, 8},
+	"i16":   {Signed, 16},
+	"i32":   {Signed, 32},
+	"i64":   {Signed, 64},
+	"u8":    {Unsigned, 8},
+	"u16":   {Unsigned, 16},
+	"u32":   {Unsigned, 32},
+	"u64":   {Unsigned, 64},
+	"usize": {Unsigned, 64},
+	"f32":   {Floating, 32},
+	"f64":   {Floating, 64},
+}
+
+// NumberType returns the representation of a numeric type name
+func NumberType(name string) (Number, bool) {
+	number, ok := numbers[name]
+	return number, ok
+}
+
+// IsInteger reports whether name is a signed or unsigned integer type
+func IsInteger(name string) bool {
+	number, ok := numbers[name]
+	return ok && number.Kind != Floating
+}
+
+// Saturate returns the bits of the integer of type n that value is converted to by a cast. Values out of range are
+// clamped to the smallest or largest value and NaN is 0.
+func (n Number) Saturate(value float64) uint64 {
+	switch {
+	case math.IsNaN(value):
+		return 0
+	case n.Kind == Unsigned:
+		if value >= math.Ldexp(1, n.Bits) {
+			return math.MaxUint64 >> (64 - n.Bits)
+		}
+		if value <= 0 {
+			return 0
+		}
+		return uint64(value)
+	}
+
+	limit := math.Ldexp(1, n.Bits-1)
+	if value >= limit {
+		return math.MaxInt64 >> (64 - n.Bits)
+	}
+	if value <= -limit {
+		smallest := int64(math.MinInt64) >> (64 - n.Bits)
+		return uint64(smallest)
+	}
+	return uint64(int64(value))
+}
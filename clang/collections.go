@@ -0,0 +1,207 @@
+package clang
+
+import (
+	"breeze/ast"
+	"breeze/scanner"
+	"fmt"
+	"strconv"
+)
+
+// Fixed arrays are structs holding the elements, so they are copied like every other value.
+// Slices reference heap memory that is shared until append has to grow it.
+
+const arrayTemplate = `typedef struct {
+    %[2]s data[%[3]d];
+} %[1]s;
+`
+
+const sliceTemplate = `typedef struct {
+    %[2]s *data;
+    int64_t length;
+    int64_t capacity;
+} %[1]s;
+
+static inline %[1]s %[1]s_make(const %[2]s *data, int64_t length) {
+    %[1]s s = {malloc(sizeof(%[2]s) * length), length, length};
+    if (length > 0) {
+        memcpy(s.data, data, sizeof(%[2]s) * length);
+    }
+    return s;
+}
+
+static inline %[2]s *%[1]s_at(%[1]s s, int64_t index, const char *location) {
+    return &s.data[bz_bounds(index, s.length, location)];
+}
+
+static inline %[1]s %[1]s_append(%[1]s s, %[2]s value) {
+    if (s.length == s.capacity) {
+        int64_t capacity = s.capacity < 4 ? 4 : s.capacity * 2;
+        %[2]s *data = malloc(sizeof(%[2]s) * capacity);
+        if (s.length > 0) {
+            memcpy(data, s.data, sizeof(%[2]s) * s.length);
+        }
+        s.data = data;
+        s.capacity = capacity;
+    }
+    s.data[s.length] = value;
+    s.length++;
+    return s;
+}
+`
+
+// mangle converts a type name to a part of a C identifier
+func mangle(name string) string {
+	if element, length, ok := ast.ArrayType(name); ok {
+		return "array_" + mangle(element) + "_" + strconv.Itoa(length)
+	}
+	if element, ok := ast.SliceType(name); ok {
+		return "slice_" + mangle(element)
+	}
+	if _, isNumber := numberTypeNames[name]; isNumber || name == "bool" || name == "string" {
+		return name
+	}
+	// Structs keep their prefix, so they cannot be mistaken for collections
+	return identifier(name)
+}
+
+// typeName converts a Breeze type to a C type and declares collection types on first use
+func (c *compiler) typeName(name string) string {
+	element, length, isArray := ast.ArrayType(name)
+	if !isArray {
+		var isSlice bool
+		element, isSlice = ast.SliceType(name)
+		if !isSlice {
+			return clangTypeName(name)
+		}
+	}
+
+	typeName := "bz_" + mangle(name)
+	if c.declared[typeName] {
+		return typeName
+	}
+	c.declared[typeName] = true
+
+	// Declare element types first
+	elementName := c.typeName(element)
+
+	if isArray {
+		c.types += fmt.Sprintf(arrayTemplate, typeName, elementName, length)
+	} else {
+		c.types += fmt.Sprintf(sliceTemplate, typeName, elementName)
+	}
+	return typeName
+}
+
+func (c *compiler) VisitArrayLitExpr(node *ast.ArrayLitExpr) any {
+	typeName := c.typeName(node.Type)
+	valueCount := len(node.Values)
+
+	if _, isSlice := ast.SliceType(node.Type); isSlice {
+		if valueCount == 0 {
+			c.body += typeName + "_make(NULL, 0)"
+			return nil
+		}
+
+		element, _ := ast.SliceType(node.Type)
+		c.body += typeName + "_make((" + c.typeName(element) + "[]){"
+		c.values(node.Values)
+		c.body += "}, " + strconv.Itoa(valueCount) + ")"
+		return nil
+	}
+
+	c.body += "((" + typeName + "){{"
+	c.values(node.Values)
+	c.body += "}})"
+	return nil
+}
+
+func (c *compiler) values(values []ast.Node) {
+	for i, value := range values {
+		_ = value.Visit(c)
+		if i != len(values)-1 {
+			c.body += ", "
+		}
+	}
+}
+
+// element writes the bounds checked access of an element, which can be assigned to
+func (c *compiler) element(node ast.Node, collectionType string, expression ast.Node, index ast.Node) {
+	location := c.location(node.GetToken())
+
+	if _, length, isArray := ast.ArrayType(collectionType); isArray {
+		c.body += "("
+		_ = expression.Visit(c)
+		c.body += ").data[bz_bounds("
+		_ = index.Visit(c)
+		c.body += fmt.Sprintf(", %d, %s)]", length, location)
+		return
+	}
+
+	c.body += "(*" + c.typeName(collectionType) + "_at("
+	_ = expression.Visit(c)
+	c.body += ", "
+	_ = index.Visit(c)
+	c.body += ", " + location + "))"
+}
+
+func (c *compiler) VisitIndexExpr(node *ast.IndexExpr) any {
+	c.element(node, node.Type, node.Expression, node.Index)
+	return nil
+}
+
+func (c *compiler) VisitSetIndexExpr(node *ast.SetIndexExpr) any {
+	if node.Operator.Id != scanner.Equals {
+		element, _, isArray := ast.ArrayType(node.Type)
+		if !isArray {
+			element, _ = ast.SliceType(node.Type)
+		}
+		c.compound(node, node.Operator, element, func() {
+			c.element(node, node.Type, node.Expression, node.Index)
+		}, node.Value)
+		return nil
+	}
+
+	c.body += "("
+	c.element(node, node.Type, node.Expression, node.Index)
+	c.body += " = "
+	_ = node.Value.Visit(c)
+	c.body += ")"
+	return nil
+}
+
+func (c *compiler) genericBuiltin(node *ast.CallExpr) any {
+	name := node.Expression.(*ast.IdentifierLitExpr).Name
+	collection := node.Arguments[0]
+
+	switch name {
+	case "len":
+		if _, length, isArray := ast.ArrayType(node.Type); isArray {
+			// Evaluate the operand for its side effects only
+			c.body += "((void) "
+			_ = collection.Visit(c)
+			c.body += fmt.Sprintf(", (int64_t) %d)", length)
+			return nil
+		}
+
+		if _, isSlice := ast.SliceType(node.Type); isSlice {
+			c.body += "("
+			_ = collection.Visit(c)
+			c.body += ").length"
+			return nil
+		}
+
+		c.body += "bz_string_length("
+		_ = collection.Visit(c)
+		c.body += ")"
+	case "append":
+		c.body += c.typeName(node.Type) + "_append("
+		_ = collection.Visit(c)
+		c.body += ", "
+		_ = node.Arguments[1].Visit(c)
+		c.body += ")"
+	default:
+		panic(fmt.Sprintf("Missing builtin translation for Clang: %s", name))
+	}
+
+	return nil
+}
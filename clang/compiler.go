@@ -4,94 +4,226 @@ import (
 	"breeze/ast"
 	"breeze/common"
 	"breeze/scanner"
-	"bytes"
 	"fmt"
-	"os/exec"
+	"path/filepath"
 )
 
-func CompileClang(executablePath string, file common.SourceFile, nodes []ast.Node) string {
-	source := CompileToSource(nodes)
+func CompileToSource(file common.SourceFile, nodes []ast.Node) string {
+	c := &compiler{
+		file:       file,
+		header:     runtimeSource,
+		types:      "",
+		prototypes: "",
+		globals:    "",
+		body:       "",
+		entry:      "",
+		declared:   make(map[string]bool),
+	}
 
-	filePath := fmt.Sprintf("%s.c", file.Path[:len(file.Path)-3]) // substring .bz
+	// Structs first, as collection types and functions declared before them may use them
+	for _, node := range nodes {
+		if node.GetId() == ast.StructId {
+			_ = node.Visit(c)
+		}
+	}
 
-	err := common.WriteFile(filePath, source)
-	if err != nil {
-		panic(err)
+	var mainDecl *ast.FunctionDecl
+	for _, node := range nodes {
+		switch node.GetId() {
+		case ast.FunctionId:
+			fn := node.(*ast.FunctionDecl)
+			if fn.Identifier == "main" {
+				mainDecl = fn
+			}
+			_ = node.Visit(c)
+		case ast.StructId:
+			// Already emitted
+		default:
+			// Top level statements run in the C main function before Breeze main
+			body := c.body
+			c.body = c.entry
+			_ = node.Visit(c)
+			c.entry = c.body
+			c.body = body
+		}
 	}
 
-	cmd := exec.Command("clang", "-o", executablePath, filePath)
-	fmt.Println("%", cmd.String())
+	switch {
+	case mainDecl == nil:
+		c.entry += "return 0;\n"
+	case len(mainDecl.ReturnType) == 0:
+		c.entry += identifier("main") + "();\nreturn 0;\n"
+	default:
+		c.entry += "return " + identifier("main") + "();\n"
+	}
 
-	var stdout = bytes.Buffer{}
-	var stderr = bytes.Buffer{}
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	return fmt.Sprintf("%s\n%s\n%s\n%s\n%s\nint main(void)\n{\n%s}\n", c.header, c.types, c.prototypes, c.globals, c.body, c.entry)
+}
 
-	err = cmd.Run()
+type compiler struct {
+	ast.Visitor
+	file common.SourceFile
+	// Runtime, followed by struct and collection types in order of declaration, function prototypes, global variables,
+	// functions and the statements of the C main function
+	header     string
+	types      string
+	prototypes string
+	globals    string
+	body       string
+	entry      string
+	declared   map[string]bool
+	// Nesting of blocks, variables declared outside of any block are global
+	depth int
+	// Loops enclosing the current statement and the number of break labels
+	loops  []*loop
+	labels int
+}
 
-	fmt.Println(stderr.String())
-	fmt.Println(stdout.String())
+// loop is a loop enclosing the current statement. In the switch of a match break leaves the switch, so it jumps to the
+// label after the loop instead.
+type loop struct {
+	// Switches in the loop enclosing the current statement
+	switches int
+	label    string
+	jumped   bool
+}
 
-	if err != nil {
-		fmt.Println(err)
-		panic(err)
-		return source
-	}
+// identifier converts a Breeze identifier to a C identifier. User identifiers are prefixed, so they do not collide
+// with the variables of the generated code, the runtime or C, and Breeze main can be called by the C main function.
+func identifier(name string) string {
+	return "bz_u_" + name
+}
 
-	return source
+// numberTypeNames are the C types of the numeric types, int and float are 64 bit wide as well
+var numberTypeNames = map[string]string{
+	"int":   "int64_t",
+	"float": "double",
+	"i8":    "int8_t",
+	"i16":   "int16_t",
+	"i32":   "int32_t",
+	"i64":   "int64_t",
+	"u8":    "uint8_t",
+	"u16":   "uint16_t",
+	"u32":   "uint32_t",
+	"u64":   "uint64_t",
+	"usize": "size_t",
+	"f32":   "float",
+	"f64":   "double",
 }
 
-func CompileToSource(nodes []ast.Node) string {
-	c := &compiler{
-		header: "",
-		body:   "",
+func clangTypeName(name string) string {
+	switch name {
+	case "":
+		return "void"
+	case "bool":
+		return "bool"
+	case "string":
+		return "bz_string"
 	}
-	for _, node := range nodes {
-		_ = node.Visit(c)
+
+	if numberType, ok := numberTypeNames[name]; ok {
+		return numberType
 	}
-	return fmt.Sprintf("%s\n%s", c.header, c.body)
+	// Structs
+	return identifier(name)
 }
 
-type compiler struct {
-	ast.Visitor
-	header string
-	body   string
+// narrow reports whether arithmetic on values of typeName is done on promoted int values in C, which have to be
+// converted back to wrap around like in the other backends
+func narrow(typeName string) bool {
+	number, ok := ast.NumberType(typeName)
+	return ok && number.Kind != ast.Floating && number.Bits < 32
 }
 
-func clangTypeName(name string) string {
-	if len(name) == 0 {
-		return "void"
+// wrapping returns the unsigned C type that arithmetic on the integer type number is done in. Overflow of signed
+// integers is undefined in C, while unsigned integers of at least 32 bits are not promoted and wrap around.
+func wrapping(number ast.Number) string {
+	if number.Bits == 64 {
+		return "uint64_t"
 	}
+	return "uint32_t"
+}
+
+// overflows reports whether operator can overflow its integer operands
+func overflows(operator scanner.TokenId) bool {
+	switch operator {
+	case scanner.Plus, scanner.Minus, scanner.Star, scanner.LowerLower:
+		return true
+	}
+	return false
+}
 
-	return name
+// location converts the position of token to a quoted prefix for runtime messages
+func (c *compiler) location(token scanner.Token) string {
+	position := token.Position
+	return quote(fmt.Sprintf("[%s:%d:%d] ", filepath.Base(c.file.Path), position.Line, position.Column))
+}
+
+// line maps the following C code to the line of node, so debuggers, sanitizers and warnings show Breeze locations
+func (c *compiler) line(node ast.Node) {
+	c.body += fmt.Sprintf("#line %d %s\n", node.GetToken().Position.Line, quote(c.file.Path))
 }
 
 func (c *compiler) VisitDebugStmt(node *ast.DebugStmt) any {
+	c.line(node)
+	location := c.location(node.GetToken())
+
+	number, isNumber := ast.NumberType(node.Type)
+	switch {
+	case isNumber && number.Kind == ast.Signed:
+		c.body += "printf(\"%s%lld\\n\", " + location + ", (long long) "
+		_ = node.Expression.Visit(c)
+	case isNumber && number.Kind == ast.Unsigned:
+		c.body += "printf(\"%s%llu\\n\", " + location + ", (unsigned long long) "
+		_ = node.Expression.Visit(c)
+	case isNumber:
+		c.body += "bz_debug_float(" + location + ", (double) "
+		_ = node.Expression.Visit(c)
+	case node.Type == "bool":
+		c.body += "printf(\"%s%s\\n\", " + location + ", "
+		_ = node.Expression.Visit(c)
+		c.body += " ? \"true\" : \"false\""
+	case node.Type == "string":
+		// Strings are not null terminated, the runtime prints them by length
+		c.body += "bz_debug_string(" + location + ", "
+		_ = node.Expression.Visit(c)
+	default:
+		panic(fmt.Sprintf("Missing debug translation for Clang: %s", node.Type))
+	}
+	c.body += ");\n"
+
 	return nil
 }
 func (c *compiler) VisitFunctionDecl(node *ast.FunctionDecl) any {
-	typeName := clangTypeName(node.ReturnType)
-
-	c.body += typeName
-	c.body += " "
-	c.body += node.Identifier
-	c.body += "("
+	signature := c.typeName(node.ReturnType)
+	signature += " "
+	signature += identifier(node.Identifier)
+	signature += "("
 	paramCount := len(node.ParamType)
+	if paramCount == 0 {
+		signature += "void"
+	}
 	for i := 0; i < paramCount; i++ {
-		c.body += clangTypeName(node.ParamType[i])
-		c.body += " "
-		c.body += node.ParamName[i]
+		signature += c.typeName(node.ParamType[i])
+		signature += " "
+		signature += identifier(node.ParamName[i])
 		if i != paramCount-1 {
-			c.body += ", "
+			signature += ", "
 		}
 	}
-	c.body += ")\n"
+	signature += ")"
+
+	// Prototypes let functions call each other regardless of their order
+	c.prototypes += signature + ";\n"
+	c.line(node)
+	c.body += signature + "\n"
 
 	_ = node.Closure.Visit(c)
 
 	return nil
 }
 func (c *compiler) VisitConditionalStmt(node *ast.ConditionalStmt) any {
+	c.line(node)
 	c.body += "if ("
 	_ = node.Condition.Visit(c)
 	c.body += ")\n"
@@ -104,22 +236,183 @@ func (c *compiler) VisitConditionalStmt(node *ast.ConditionalStmt) any {
 	return nil
 }
 func (c *compiler) VisitLetDecl(node *ast.LetDecl) any {
-	c.body += node.Type + " " + node.Identifier + ";\n"
+	declaration := c.typeName(node.Type) + " " + identifier(node.Identifier) + ";\n"
+	if c.depth == 0 {
+		c.globals += declaration
+		return nil
+	}
+	c.line(node)
+	c.body += declaration
+	return nil
+}
+func (c *compiler) VisitWhileStmt(node *ast.WhileStmt) any {
+	c.line(node)
+	c.beginLoop()
+	c.body += "while ("
+	_ = node.Condition.Visit(c)
+	c.body += ")\n"
+	_ = node.Statement.Visit(c)
+	c.endLoop()
+
+	return nil
+}
+
+func (c *compiler) beginLoop() {
+	c.labels++
+	c.loops = append(c.loops, &loop{label: fmt.Sprintf("bz_break_%d", c.labels)})
+}
+
+// endLoop emits the label after the loop if a break jumps to it
+func (c *compiler) endLoop() {
+	l := c.loops[len(c.loops)-1]
+	c.loops = c.loops[:len(c.loops)-1]
+	if l.jumped {
+		c.body += l.label + ":;\n"
+	}
+}
+func (c *compiler) VisitForStmt(node *ast.ForStmt) any {
+	// The block scopes the variables of the initializer
+	c.depth++
+	c.body += "{\n"
+	if node.Initializer != nil {
+		_ = node.Initializer.Visit(c)
+	}
+
+	c.line(node)
+	c.body += "for (; "
+	if node.Condition != nil {
+		_ = node.Condition.Visit(c)
+	}
+	c.body += "; "
+	if node.Increment != nil {
+		_ = node.Increment.Visit(c)
+	}
+	c.body += ")\n"
+	c.beginLoop()
+	_ = node.Statement.Visit(c)
+	c.endLoop()
+
+	c.body += "}\n"
+	c.depth--
+	return nil
+}
+
+// VisitForInStmt evaluates the range or collection once and binds the name to a copy of the value in every iteration.
+// The hidden variables of nested loops shadow each other.
+func (c *compiler) VisitForInStmt(node *ast.ForInStmt) any {
+	c.depth++
+	c.line(node)
+	c.body += "{\n"
+
+	var elementType, value string
+	if node.End != nil {
+		elementType = clangTypeName(node.Type)
+		c.body += elementType + " bz_index = "
+		_ = node.Expression.Visit(c)
+		c.body += ";\n" + elementType + " bz_end = "
+		_ = node.End.Visit(c)
+		c.body += ";\n"
+		value = "bz_index"
+	} else {
+		c.body += c.typeName(node.Type) + " bz_collection = "
+		_ = node.Expression.Visit(c)
+		c.body += ";\n"
+
+		element, length, isArray := ast.ArrayType(node.Type)
+		if !isArray {
+			element, _ = ast.SliceType(node.Type)
+		}
+		elementType = c.typeName(element)
+		c.body += "int64_t bz_index = 0;\n"
+		if isArray {
+			c.body += fmt.Sprintf("int64_t bz_end = %d;\n", length)
+		} else {
+			c.body += "int64_t bz_end = bz_collection.length;\n"
+		}
+		value = "bz_collection.data[bz_index]"
+	}
+
+	c.body += "for (; bz_index < bz_end; bz_index++)\n{\n"
+	c.body += elementType + " " + identifier(node.Name.Lexeme) + " = " + value + ";\n"
+	c.beginLoop()
+	_ = node.Statement.Visit(c)
+	c.body += "}\n"
+	c.endLoop()
+	c.body += "}\n"
+
+	c.depth--
 	return nil
 }
-func (c *compiler) VisitWhileStmt(node *ast.WhileStmt) any { return nil }
 func (c *compiler) VisitAssignExpr(node *ast.AssignExpr) any {
-	c.body += "(" + node.Name.Lexeme + " = "
-	node.Value.Visit(c)
+	name := identifier(node.Name.Lexeme)
+	c.body += "(" + name + " = "
+	if node.Operator.Id == scanner.Equals {
+		node.Value.Visit(c)
+	} else {
+		c.binary(binaryOperator(node, node.Operator), node.Type, func() {
+			c.body += name
+		}, node.Value)
+	}
 	c.body += ")"
 
 	return nil
 }
+
+// binaryOperator returns the binary operator applied by the compound assignment operator, located at the node
+// like the runtime errors of the other backends
+func binaryOperator(node ast.Node, operator scanner.Token) scanner.Token {
+	operator.Id, _ = scanner.CompoundOperator(operator.Id)
+	operator.Position = node.GetToken().Position
+	return operator
+}
+
+// compound emits a compound assignment to the value of typeName that target emits, like target = target + value.
+// The target is evaluated once through a pointer.
+func (c *compiler) compound(node ast.Node, operator scanner.Token, typeName string, target func(), value ast.Node) {
+	c.body += "({\n" + clangTypeName(typeName) + " *bz_target = &("
+	target()
+	c.body += ");\n*bz_target = "
+	c.binary(binaryOperator(node, operator), typeName, func() {
+		c.body += "*bz_target"
+	}, value)
+	c.body += ";\n})"
+}
+
 func (c *compiler) VisitBinaryExpr(node *ast.BinaryExpr) any {
-	c.body += "("
-	_ = node.Left.Visit(c)
+	if node.Type == "string" {
+		return c.stringBinaryExpr(node)
+	}
 
-	switch node.Operator.Id {
+	c.binary(node.Operator, node.Type, func() {
+		_ = node.Left.Visit(c)
+	}, node.Right)
+	return nil
+}
+
+// binary emits operator applied to operands of typeName, left emits the left operand
+func (c *compiler) binary(operator scanner.Token, typeName string, left func(), right ast.Node) {
+	// Operations that overflow are done on unsigned values and converted back, so they wrap around like in the other
+	// backends. The shift count keeps its type.
+	number, _ := ast.NumberType(typeName)
+	integer := ast.IsInteger(typeName) && !isComparison(operator.Id)
+	if integer && (operator.Id == scanner.Slash || operator.Id == scanner.Percent) {
+		c.division(operator, number, typeName, left, right)
+		return
+	}
+	unsigned := ""
+	if integer && overflows(operator.Id) {
+		unsigned = "(" + wrapping(number) + ") "
+	}
+
+	wrap := len(unsigned) > 0 || integer && narrow(typeName)
+	if wrap {
+		c.body += "((" + clangTypeName(typeName) + ") "
+	}
+
+	c.body += "(" + unsigned
+	left()
+
+	switch operator.Id {
 	case scanner.Plus:
 		c.body += "+"
 	case scanner.Minus:
@@ -128,6 +421,18 @@ func (c *compiler) VisitBinaryExpr(node *ast.BinaryExpr) any {
 		c.body += "*"
 	case scanner.Slash:
 		c.body += "/"
+	case scanner.Percent:
+		c.body += "%"
+	case scanner.And:
+		c.body += "&"
+	case scanner.Pipe:
+		c.body += "|"
+	case scanner.Caret:
+		c.body += "^"
+	case scanner.LowerLower:
+		c.body += "<<"
+	case scanner.GreaterGreater:
+		c.body += ">>"
 	case scanner.Lower:
 		c.body += "<"
 	case scanner.Greater:
@@ -145,9 +450,68 @@ func (c *compiler) VisitBinaryExpr(node *ast.BinaryExpr) any {
 	case scanner.PipePipe:
 		c.body += "||"
 	default:
-		panic(fmt.Sprintf("Missing binary operation translation for Clang: %d ", node.Operator.Id))
+		panic(fmt.Sprintf("Missing binary operation translation for Clang: %d ", operator.Id))
+	}
+
+	if operator.Id != scanner.LowerLower {
+		c.body += unsigned
+	}
+	if operator.Id == scanner.LowerLower || operator.Id == scanner.GreaterGreater {
+		// Shifting by the width or more is undefined in C
+		c.body += "bz_shift("
+		_ = right.Visit(c)
+		c.body += fmt.Sprintf(", %d, %s)", number.Bits, c.location(operator))
+	} else {
+		_ = right.Visit(c)
+	}
+	c.body += ")"
+	if wrap {
+		c.body += ")"
+	}
+}
+
+// division emits an integer division or remainder through the runtime, as division by zero and the overflowing
+// division of the smallest integer by -1 trap in C
+func (c *compiler) division(operator scanner.Token, number ast.Number, typeName string, left func(), right ast.Node) {
+	function := "bz_div_"
+	if operator.Id == scanner.Percent {
+		function = "bz_mod_"
+	}
+	if number.Kind == ast.Signed {
+		function += "signed"
+	} else {
+		function += "unsigned"
+	}
+
+	c.body += "((" + clangTypeName(typeName) + ") " + function + "("
+	left()
+	c.body += ", "
+	_ = right.Visit(c)
+	c.body += ", " + c.location(operator) + "))"
+}
+
+func isComparison(operator scanner.TokenId) bool {
+	switch operator {
+	case scanner.Lower, scanner.Greater, scanner.LowerEquals, scanner.GreaterEquals, scanner.EqualsEquals, scanner.BangEquals, scanner.AndAnd, scanner.PipePipe:
+		return true
+	}
+	return false
+}
+
+func (c *compiler) stringBinaryExpr(node *ast.BinaryExpr) any {
+	switch node.Operator.Id {
+	case scanner.Plus:
+		c.body += "bz_string_concat("
+	case scanner.EqualsEquals:
+		c.body += "bz_string_equals("
+	case scanner.BangEquals:
+		c.body += "!bz_string_equals("
+	default:
+		panic(fmt.Sprintf("Missing string operation translation for Clang: %d ", node.Operator.Id))
 	}
 
+	_ = node.Left.Visit(c)
+	c.body += ", "
 	_ = node.Right.Visit(c)
 	c.body += ")"
 
@@ -160,6 +524,7 @@ func (c *compiler) VisitBlockStmt(node *ast.BlockStmt) any {
 	return nil
 }
 func (c *compiler) VisitReturnStmt(node *ast.ReturnStmt) any {
+	c.line(node)
 	c.body += "return"
 	if node.Expression != nil {
 		c.body += " "
@@ -169,35 +534,81 @@ func (c *compiler) VisitReturnStmt(node *ast.ReturnStmt) any {
 	return nil
 }
 func (c *compiler) VisitContinueStmt(node *ast.ContinueStmt) any {
+	c.line(node)
 	c.body += "continue;\n"
 	return nil
 }
 func (c *compiler) VisitBreakStmt(node *ast.BreakStmt) any {
+	c.line(node)
+	if len(c.loops) > 0 && c.loops[len(c.loops)-1].switches > 0 {
+		l := c.loops[len(c.loops)-1]
+		l.jumped = true
+		c.body += "goto " + l.label + ";\n"
+		return nil
+	}
 	c.body += "break;\n"
 	return nil
 }
 func (c *compiler) VisitUnaryExpr(node *ast.UnaryExpr) any {
+	// The analyzer allows 1 << 63 only if negated, C has no literal for the smallest value
+	if literal, ok := node.Expression.(*ast.IntegerLitExpr); ok && node.Operator.Id == scanner.Minus && literal.Value == "9223372036854775808" {
+		c.body += "(-9223372036854775807 - 1)"
+		return nil
+	}
+
+	// Negating the smallest value overflows, so integers are negated as unsigned values like in binary expressions.
+	// Literals are in range. Inverting a narrow type is promoted to int.
+	number, _ := ast.NumberType(node.Type)
+	_, isLiteral := node.Expression.(*ast.IntegerLitExpr)
+	unsigned := ""
+	if node.Operator.Id == scanner.Minus && ast.IsInteger(node.Type) && !isLiteral {
+		unsigned = "(" + wrapping(number) + ") "
+	}
+
+	wrap := len(unsigned) > 0 || narrow(node.Type) && node.Operator.Id == scanner.Tilde
+	if wrap {
+		c.body += "((" + clangTypeName(node.Type) + ") "
+	}
+
 	c.body += "("
 	switch node.Operator.Id {
 	case scanner.Minus:
 		c.body += "-"
+	case scanner.Tilde:
+		c.body += "~"
 	}
+	c.body += unsigned
 	node.Expression.Visit(c)
 	c.body += ")"
 
+	if wrap {
+		c.body += ")"
+	}
+
 	return nil
 }
 func (c *compiler) VisitFloatingLitExpr(node *ast.FloatingLitExpr) any {
 	c.body += node.Value
+	if number, _ := ast.NumberType(node.Type); number.Bits == 32 {
+		// Keeps arithmetic with f32 values in single precision
+		c.body += "f"
+	}
 	return nil
 }
 func (c *compiler) VisitClosureStmt(node *ast.ClosureStmt) any {
+	c.depth++
 	c.body += "{\n"
 	node.Block.Visit(c)
 	c.body += "}\n"
+	c.depth--
 	return nil
 }
 func (c *compiler) VisitCallExpr(node *ast.CallExpr) any {
+	if len(node.Type) > 0 {
+		// Only builtins taking collections have an operand type
+		return c.genericBuiltin(node)
+	}
+
 	node.Expression.Visit(c)
 	c.body += "("
 	argCount := len(node.Arguments)
@@ -215,20 +626,215 @@ func (c *compiler) VisitBooleanLitExpr(node *ast.BooleanLitExpr) any {
 	return nil
 }
 func (c *compiler) VisitExprStmt(node *ast.ExprStmt) any {
+	c.line(node)
+	if match, ok := node.Expression.(*ast.MatchExpr); ok {
+		c.match(match, false)
+		return nil
+	}
 	_ = node.Expression.Visit(c)
 	c.body += ";\n"
 	return nil
 }
 func (c *compiler) VisitIdentifierLitExpr(node *ast.IdentifierLitExpr) any {
-	c.body += node.Name
+	c.body += identifier(node.Name)
 	return nil
 }
 func (c *compiler) VisitErrNode(node *ast.ErrNode) any {
 	// Should NEVER be called, maybe analysis stage missed?
 	panic(node)
-	return nil
 }
 func (c *compiler) VisitIntegerLitExpr(node *ast.IntegerLitExpr) any {
-	c.body += node.Value
+	// Bare literals are ints of 32 bits, so arithmetic on them alone would overflow before it is stored
+	number, _ := ast.NumberType(node.Type)
+	switch {
+	case number.Bits == 64 && number.Kind == ast.Unsigned:
+		c.body += "UINT64_C(" + node.Value + ")"
+	case number.Bits == 64:
+		c.body += "INT64_C(" + node.Value + ")"
+	case number.Kind == ast.Unsigned:
+		c.body += node.Value + "u"
+	default:
+		c.body += node.Value
+	}
+	return nil
+}
+func (c *compiler) VisitStringLitExpr(node *ast.StringLitExpr) any {
+	c.body += stringLiteral(node.Value)
+	return nil
+}
+func (c *compiler) VisitStructDecl(node *ast.StructDecl) any {
+	// Field types are declared first, as they are written to the type section as well
+	fields := ""
+	for i, fieldName := range node.FieldName {
+		fields += c.typeName(node.FieldType[i]) + " " + identifier(fieldName) + ";\n"
+	}
+
+	name := identifier(node.Identifier)
+	c.types += "typedef struct " + name + " {\n" + fields + "} " + name + ";\n"
+	return nil
+}
+func (c *compiler) VisitStructLitExpr(node *ast.StructLitExpr) any {
+	// Designated initializers keep the evaluation order of the literal
+	c.body += "((" + identifier(node.Identifier) + "){"
+	fieldCount := len(node.Fields)
+	for i, field := range node.Fields {
+		c.body += "." + identifier(field.Lexeme) + " = "
+		_ = node.Values[i].Visit(c)
+		if i != fieldCount-1 {
+			c.body += ", "
+		}
+	}
+	c.body += "})"
 	return nil
 }
+func (c *compiler) VisitGetExpr(node *ast.GetExpr) any {
+	_ = node.Expression.Visit(c)
+	c.body += "." + identifier(node.Name.Lexeme)
+	return nil
+}
+func (c *compiler) VisitSetExpr(node *ast.SetExpr) any {
+	field := func() {
+		c.body += "("
+		_ = node.Expression.Visit(c)
+		c.body += ")." + identifier(node.Name.Lexeme)
+	}
+	if node.Operator.Id != scanner.Equals {
+		c.compound(node, node.Operator, node.Type, field, node.Value)
+		return nil
+	}
+
+	c.body += "("
+	field()
+	c.body += " = "
+	_ = node.Value.Visit(c)
+	c.body += ")"
+
+	return nil
+}
+func (c *compiler) VisitCastExpr(node *ast.CastExpr) any {
+	c.body += "((" + clangTypeName(node.TargetType) + ") "
+
+	// Converting floats out of range to integers is undefined in C, so they saturate like in the other backends
+	from, _ := ast.NumberType(node.Type)
+	to, _ := ast.NumberType(node.TargetType)
+	if from.Kind == ast.Floating && to.Kind != ast.Floating {
+		saturate := "bz_saturate_signed("
+		if to.Kind == ast.Unsigned {
+			saturate = "bz_saturate_unsigned("
+		}
+		c.body += saturate
+		_ = node.Expression.Visit(c)
+		c.body += fmt.Sprintf(", %d))", to.Bits)
+		return nil
+	}
+
+	_ = node.Expression.Visit(c)
+	c.body += ")"
+	return nil
+}
+
+// VisitMatchExpr stores the value of the matching arm in a variable of a statement expression
+func (c *compiler) VisitMatchExpr(node *ast.MatchExpr) any {
+	c.body += "({\n" + c.typeName(node.ResultType) + " bz_result;\n"
+	c.match(node, true)
+	c.body += "bz_result;\n})"
+	return nil
+}
+
+// match lowers matches over integers to switch and other matches to if and else. With keep set the value of the arm is
+// assigned to bz_result.
+func (c *compiler) match(node *ast.MatchExpr, keep bool) {
+	// Arms after _ are never reached, a switch would run them for their cases
+	arms, patterns := node.Arms, node.Patterns
+	for i, alternatives := range patterns {
+		if len(alternatives) == 0 {
+			arms, patterns = arms[:i+1], patterns[:i+1]
+			break
+		}
+	}
+
+	if ast.IsInteger(node.Type) {
+		c.switchMatch(node, arms, patterns, keep)
+	} else {
+		c.conditionalMatch(node, arms, patterns, keep)
+	}
+}
+
+func (c *compiler) switchMatch(node *ast.MatchExpr, arms []ast.Node, patterns [][]ast.Node, keep bool) {
+	c.body += "switch ("
+	_ = node.Value.Visit(c)
+	c.body += ")\n{\n"
+
+	if len(c.loops) > 0 {
+		l := c.loops[len(c.loops)-1]
+		l.switches++
+		defer func() {
+			l.switches--
+		}()
+	}
+
+	for i, arm := range arms {
+		if len(patterns[i]) == 0 {
+			c.body += "default:\n"
+		}
+		for _, pattern := range patterns[i] {
+			c.body += "case "
+			_ = pattern.Visit(c)
+			c.body += ":\n"
+		}
+		c.arm(arm, keep)
+		c.body += "break;\n"
+	}
+	c.body += "}\n"
+}
+
+// conditionalMatch evaluates the value once and compares it with the patterns of each arm
+func (c *compiler) conditionalMatch(node *ast.MatchExpr, arms []ast.Node, patterns [][]ast.Node, keep bool) {
+	c.body += "{\n" + c.typeName(node.Type) + " bz_match = "
+	_ = node.Value.Visit(c)
+	c.body += ";\n"
+
+	for i, arm := range arms {
+		if i > 0 {
+			c.body += "else "
+		}
+
+		// Match values and matches over bools are exhaustive, so the last arm needs no check
+		last := i == len(arms)-1 && (keep || node.Type == "bool")
+		if len(patterns[i]) == 0 || last {
+			c.body += "\n"
+		} else {
+			c.body += "if ("
+			for j, pattern := range patterns[i] {
+				if j > 0 {
+					c.body += " || "
+				}
+				if node.Type == "string" {
+					c.body += "bz_string_equals(bz_match, "
+					_ = pattern.Visit(c)
+					c.body += ")"
+				} else {
+					c.body += "bz_match == "
+					_ = pattern.Visit(c)
+				}
+			}
+			c.body += ")\n"
+		}
+		c.arm(arm, keep)
+	}
+	c.body += "}\n"
+}
+
+func (c *compiler) arm(arm ast.Node, keep bool) {
+	switch {
+	case keep:
+		c.body += "bz_result = "
+		_ = arm.Visit(c)
+		c.body += ";\n"
+	case arm.GetId() == ast.ClosureId:
+		_ = arm.Visit(c)
+	default:
+		_ = arm.Visit(c)
+		c.body += ";\n"
+	}
+}
@@ -0,0 +1,152 @@
+package clang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// runtimeSource is the C runtime every translation unit is prefixed with.
+const runtimeSource = `#include <stdbool.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+typedef struct {
+    const char *data;
+    int64_t length;
+} bz_string;
+
+static inline bz_string bz_string_concat(bz_string a, bz_string b) {
+    int64_t length = a.length + b.length;
+    char *data = malloc(length + 1);
+    memcpy(data, a.data, a.length);
+    memcpy(data + a.length, b.data, b.length);
+    data[length] = '\0';
+    return (bz_string){data, length};
+}
+
+static inline int bz_string_equals(bz_string a, bz_string b) {
+    return a.length == b.length && memcmp(a.data, b.data, a.length) == 0;
+}
+
+static inline int64_t bz_string_length(bz_string s) {
+    return s.length;
+}
+
+static inline void bz_debug_string(const char *location, bz_string s) {
+    // Strings may contain null characters, which end %s
+    fputs(location, stdout);
+    if (s.length > 0) {
+        fwrite(s.data, 1, s.length, stdout);
+    }
+    putchar('\n');
+}
+
+static inline int64_t bz_shift(int64_t count, int64_t bits, const char *location) {
+    if (count < 0 || count >= bits) {
+        fprintf(stderr, "%sshift count out of range for %lld bits\n", location, (long long) bits);
+        exit(1);
+    }
+    return count;
+}
+
+static inline void bz_debug_float(const char *location, double value) {
+    // The sign of NaN depends on the platform
+    if (value != value) {
+        printf("%snan\n", location);
+        return;
+    }
+    printf("%s%g\n", location, value);
+}
+
+static inline void bz_divisor(int zero, const char *location) {
+    if (zero) {
+        fprintf(stderr, "%sdivision by zero\n", location);
+        exit(1);
+    }
+}
+
+static inline int64_t bz_div_signed(int64_t a, int64_t b, const char *location) {
+    bz_divisor(b == 0, location);
+    // The smallest integer divided by -1 overflows and wraps around to itself
+    return b == -1 ? (int64_t) (0 - (uint64_t) a) : a / b;
+}
+
+static inline int64_t bz_mod_signed(int64_t a, int64_t b, const char *location) {
+    bz_divisor(b == 0, location);
+    return b == -1 ? 0 : a % b;
+}
+
+static inline uint64_t bz_div_unsigned(uint64_t a, uint64_t b, const char *location) {
+    bz_divisor(b == 0, location);
+    return a / b;
+}
+
+static inline uint64_t bz_mod_unsigned(uint64_t a, uint64_t b, const char *location) {
+    bz_divisor(b == 0, location);
+    return a % b;
+}
+
+static inline int64_t bz_saturate_signed(double value, int bits) {
+    uint64_t largest = ((uint64_t) 1 << (bits - 1)) - 1;
+    if (value != value) {
+        return 0;
+    }
+    if (value >= (double) largest + 1.0) {
+        return (int64_t) largest;
+    }
+    if (value <= -((double) largest + 1.0)) {
+        return -(int64_t) largest - 1;
+    }
+    return (int64_t) value;
+}
+
+static inline uint64_t bz_saturate_unsigned(double value, int bits) {
+    uint64_t largest = bits == 64 ? UINT64_MAX : ((uint64_t) 1 << bits) - 1;
+    if (value != value || value <= 0) {
+        return 0;
+    }
+    if (value >= (double) largest + 1.0) {
+        return largest;
+    }
+    return (uint64_t) value;
+}
+
+static inline int64_t bz_bounds(int64_t index, int64_t length, const char *location) {
+    if (index < 0 || index >= length) {
+        fprintf(stderr, "%sindex out of bounds: index %lld, length %lld\n", location, (long long) index, (long long) length);
+        exit(1);
+    }
+    return index;
+}
+`
+
+// stringLiteral converts value to a bz_string compound literal.
+func stringLiteral(value string) string {
+	return fmt.Sprintf("((bz_string){%s, %d})", quote(value), len(value))
+}
+
+// quote converts value to a C string literal. Octal escapes are used, as they end after at most three digits.
+func quote(value string) string {
+	builder := strings.Builder{}
+	builder.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		switch {
+		case b == '"' || b == '\\':
+			builder.WriteByte('\\')
+			builder.WriteByte(b)
+		case b == '\n':
+			builder.WriteString("\\n")
+		case b == '\t':
+			builder.WriteString("\\t")
+		case b < 0x20 || b >= 0x7f:
+			builder.WriteString(fmt.Sprintf("\\%03o", b))
+		default:
+			builder.WriteByte(b)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
@@ -0,0 +1,132 @@
+package clang
+
+import (
+	"breeze/ast"
+	"breeze/common"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Toolchain configures the C compiler that turns the generated source into an executable
+type Toolchain struct {
+	// Compiler command like clang, gcc or tcc, may contain arguments like "ccache gcc"
+	Compiler     string
+	Optimization int
+	// Debug info, the generated source maps it to Breeze source lines
+	Debug   bool
+	CFlags  []string
+	LDFlags []string
+	// Sanitizers like address,undefined
+	Sanitize string
+	// Keep the directory of intermediate files instead of removing it
+	KeepTemps bool
+	// Commands are printed to Verbose if set
+	Verbose io.Writer
+}
+
+// DefaultCompiler is $CC if set, otherwise clang
+func DefaultCompiler() string {
+	compiler := strings.TrimSpace(os.Getenv("CC"))
+	if len(compiler) == 0 {
+		return "clang"
+	}
+	return compiler
+}
+
+// CompilerNotFoundError is returned if the C compiler is not installed
+type CompilerNotFoundError struct {
+	Compiler string
+}
+
+func (e *CompilerNotFoundError) Error() string {
+	return fmt.Sprintf("C compiler %s not found", e.Compiler)
+}
+
+// CompilerError is returned if the C compiler rejected the generated source
+type CompilerError struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e *CompilerError) Error() string {
+	return fmt.Sprintf("C compiler failed: %s", e.Err.Error())
+}
+
+func (e *CompilerError) Unwrap() error {
+	return e.Err
+}
+
+// arguments builds the command line compiling source to executable
+func (t *Toolchain) arguments(source string, executable string) []string {
+	arguments := strings.Fields(t.Compiler)
+	arguments = append(arguments, fmt.Sprintf("-O%d", t.Optimization))
+	if t.Debug {
+		arguments = append(arguments, "-g")
+	}
+	if len(t.Sanitize) > 0 {
+		arguments = append(arguments, "-fsanitize="+t.Sanitize, "-fno-omit-frame-pointer")
+	}
+	arguments = append(arguments, t.CFlags...)
+	arguments = append(arguments, "-o", executable, source)
+	// Libraries have to follow the sources that use them
+	arguments = append(arguments, t.LDFlags...)
+	return arguments
+}
+
+// Compile translates nodes to C and builds the executable at executablePath with the toolchain.
+// The directory of intermediate files is returned, it only exists if the toolchain keeps them.
+func Compile(executablePath string, file common.SourceFile, nodes []ast.Node, toolchain Toolchain) (string, error) {
+	tempDir, err := os.MkdirTemp("", "breeze-")
+	if err != nil {
+		return "", err
+	}
+	if !toolchain.KeepTemps {
+		defer func() {
+			_ = os.RemoveAll(tempDir)
+		}()
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file.Path), filepath.Ext(file.Path))
+	sourcePath := filepath.Join(tempDir, name+".c")
+
+	err = common.WriteFile(sourcePath, CompileToSource(file, nodes))
+	if err != nil {
+		return tempDir, err
+	}
+
+	arguments := toolchain.arguments(sourcePath, executablePath)
+	if len(arguments) == 0 {
+		return tempDir, &CompilerNotFoundError{Compiler: toolchain.Compiler}
+	}
+
+	cmd := exec.Command(arguments[0], arguments[1:]...)
+	if toolchain.Verbose != nil {
+		_, _ = fmt.Fprintln(toolchain.Verbose, "%", cmd.String())
+	}
+
+	var output = bytes.Buffer{}
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err = cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return tempDir, &CompilerNotFoundError{Compiler: arguments[0]}
+	}
+	if err != nil {
+		return tempDir, &CompilerError{Command: cmd.String(), Output: output.String(), Err: err}
+	}
+
+	if toolchain.Verbose != nil {
+		// Warnings of the C compiler
+		_, _ = fmt.Fprint(toolchain.Verbose, output.String())
+	}
+
+	return tempDir, nil
+}
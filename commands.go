@@ -0,0 +1,349 @@
+package main
+
+import (
+	"breeze/ast"
+	"breeze/clang"
+	"breeze/common"
+	"breeze/out"
+	"breeze/repl"
+	"breeze/slow"
+	"breeze/vm"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func newFlagSet(name string, arguments string, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: breeze %s %s\n\n%s\n", name, arguments, description)
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			_, _ = fmt.Fprintln(flags.Output(), "\nOptions:")
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
+
+// parseFlags parses args into flags. If the command should not continue, the exit code is returned as well.
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return out.ExOk, false
+	}
+	if err != nil {
+		return out.ExUsage, false
+	}
+	return out.ExOk, true
+}
+
+func diagnosticsFormatFlag(flags *flag.FlagSet) *string {
+	return flags.String("diagnostics-format", formatHuman, "format of compiler diagnostics: human, json or sarif")
+}
+
+func validDiagnosticsFormat(format string) bool {
+	return format == formatHuman || format == formatJSON || format == formatSARIF
+}
+
+type toolchainOptions struct {
+	compiler     *string
+	optimization int
+	debug        *bool
+	cflags       *string
+	ldflags      *string
+	sanitize     *string
+	keepTemps    *bool
+	verbose      *bool
+}
+
+// toolchainFlags registers the options of the C toolchain
+func toolchainFlags(flags *flag.FlagSet) *toolchainOptions {
+	options := &toolchainOptions{
+		compiler:  flags.String("cc", clang.DefaultCompiler(), "C compiler like clang, gcc or tcc, $CC if set"),
+		debug:     flags.Bool("g", false, "generate debug info for Breeze source lines"),
+		cflags:    flags.String("cflags", "", "extra flags passed to the C compiler"),
+		ldflags:   flags.String("ldflags", "", "extra flags passed to the linker, after the source"),
+		sanitize:  flags.String("sanitize", "", "sanitizers for debug builds, e.g. address,undefined"),
+		keepTemps: flags.Bool("keep-temps", false, "keep intermediate files like the generated C source"),
+		verbose:   flags.Bool("v", false, "print the C compiler command and its warnings"),
+	}
+	for level := 0; level <= 3; level++ {
+		level := level
+		flags.BoolFunc(fmt.Sprintf("O%d", level), fmt.Sprintf("optimization level %d of the C compiler", level), func(string) error {
+			options.optimization = level
+			return nil
+		})
+	}
+	return options
+}
+
+func (o *toolchainOptions) toolchain() clang.Toolchain {
+	toolchain := clang.Toolchain{
+		Compiler:     *o.compiler,
+		Optimization: o.optimization,
+		Debug:        *o.debug,
+		CFlags:       strings.Fields(*o.cflags),
+		LDFlags:      strings.Fields(*o.ldflags),
+		Sanitize:     *o.sanitize,
+		KeepTemps:    *o.keepTemps,
+	}
+	if *o.verbose {
+		toolchain.Verbose = os.Stderr
+	}
+	return toolchain
+}
+
+// compileExecutable builds the executable of result and reports errors of the C toolchain
+func compileExecutable(executablePath string, result unit, options *toolchainOptions) int {
+	tempDir, err := clang.Compile(executablePath, result.file, result.nodes, options.toolchain())
+	if *options.keepTemps && len(tempDir) > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Kept intermediate files in %s\n", tempDir)
+	}
+
+	var notFound *clang.CompilerNotFoundError
+	var compilerError *clang.CompilerError
+	switch {
+	case err == nil:
+		return out.ExOk
+	case errors.As(err, &notFound):
+		out.PrintErrorMessage(notFound.Error())
+		out.PrintHintMessage("Install clang, gcc or tcc, or select a C compiler with --cc or $CC", out.ColorBlue)
+		return out.ExUnavailable
+	case errors.As(err, &compilerError):
+		out.PrintErrorMessage(compilerError.Error())
+		out.PrintHintMessage(compilerError.Command, out.ColorBlue)
+		_, _ = fmt.Fprint(os.Stderr, compilerError.Output)
+		return out.ExSoftware
+	}
+
+	out.PrintErrorMessage(fmt.Sprintf("Could not write intermediate files: %s", err.Error()))
+	return out.ExCantCreat
+}
+
+func usageError(flags *flag.FlagSet, message string) int {
+	out.PrintErrorMessage(message)
+	flags.Usage()
+	return out.ExUsage
+}
+
+func checkCommand(args []string) int {
+	flags := newFlagSet("check", "[options] <file.bz>", "Scans, parses and analyzes a source file without producing any output.")
+	format := diagnosticsFormatFlag(flags)
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !validDiagnosticsFormat(*format) {
+		return usageError(flags, fmt.Sprintf("Unknown diagnostics format %s", *format))
+	}
+	if flags.NArg() != 1 {
+		return usageError(flags, "Expected exactly one source file")
+	}
+
+	_, code := compile(flags.Arg(0), stageAnalyze, *format)
+	return code
+}
+
+func buildCommand(args []string) int {
+	flags := newFlagSet("build", "[options] <file.bz>", "Compiles a source file to an executable using a C compiler.")
+	format := diagnosticsFormatFlag(flags)
+	toolchain := toolchainFlags(flags)
+	output := flags.String("o", "", "path of the executable (default: source file name without extension)")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !validDiagnosticsFormat(*format) {
+		return usageError(flags, fmt.Sprintf("Unknown diagnostics format %s", *format))
+	}
+	if flags.NArg() != 1 {
+		return usageError(flags, "Expected exactly one source file")
+	}
+
+	path := flags.Arg(0)
+	executablePath := *output
+	if len(executablePath) == 0 {
+		executablePath = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if sameFile(executablePath, path) {
+		out.PrintErrorMessage(fmt.Sprintf("Executable would overwrite the source file %s, select another path with -o", path))
+		return out.ExCantCreat
+	}
+
+	result, code := compile(path, stageAnalyze, *format)
+	if code != out.ExOk {
+		return code
+	}
+
+	return compileExecutable(executablePath, result, toolchain)
+}
+
+// sameFile reports whether both paths exist and refer to the same file
+func sameFile(a string, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	return err == nil && os.SameFile(aInfo, bInfo)
+}
+
+func runCommand(args []string) int {
+	flags := newFlagSet("run", "[options] <file.bz> [arguments...]", "Compiles and executes a source file. Arguments after the source file are passed to the program\nand the exit code of the program is returned.")
+	format := diagnosticsFormatFlag(flags)
+	toolchain := toolchainFlags(flags)
+	interp := flags.Bool("interp", false, "run with the tree-walking interpreter instead of a C compiler")
+	bytecode := flags.Bool("vm", false, "run with the bytecode virtual machine instead of a C compiler")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !validDiagnosticsFormat(*format) {
+		return usageError(flags, fmt.Sprintf("Unknown diagnostics format %s", *format))
+	}
+	if flags.NArg() < 1 {
+		return usageError(flags, "Expected a source file")
+	}
+
+	result, code := compile(flags.Arg(0), stageAnalyze, *format)
+	if code != out.ExOk {
+		return code
+	}
+
+	if *interp && *bytecode {
+		return usageError(flags, "Only one of --interp and --vm can be used")
+	}
+	if *interp {
+		return interpret(result, slow.Run)
+	}
+	if *bytecode {
+		return interpret(result, vm.Run)
+	}
+
+	tempDir, err := os.MkdirTemp("", "breeze-run-")
+	if err != nil {
+		out.PrintErrorMessage(fmt.Sprintf("Could not create temporary directory: %s", err.Error()))
+		return out.ExCantCreat
+	}
+	defer func() {
+		_ = os.RemoveAll(tempDir)
+	}()
+
+	executablePath := filepath.Join(tempDir, "program")
+	code = compileExecutable(executablePath, result, toolchain)
+	if code != out.ExOk {
+		return code
+	}
+
+	cmd := exec.Command(executablePath, flags.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if err == nil {
+		return out.ExOk
+	}
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		if name, number, ok := terminatingSignal(exitError.ProcessState); ok {
+			out.PrintErrorMessage(fmt.Sprintf("Program terminated by signal %s", name))
+			// Exit like shells do for terminated commands
+			return 128 + number
+		}
+		if exitError.ExitCode() < 0 {
+			out.PrintErrorMessage("Program terminated")
+			return out.ExSoftware
+		}
+		return exitError.ExitCode()
+	}
+
+	out.PrintErrorMessage(fmt.Sprintf("Could not execute %s: %s", executablePath, err.Error()))
+	return out.ExOsErr
+}
+
+// interpret runs result without a C compiler, runtime errors exit like compiled programs
+func interpret(result unit, run func(common.SourceFile, []ast.Node, io.Writer) (int, error)) int {
+	code, err := run(result.file, result.nodes, os.Stdout)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return code
+}
+
+func emitCommand(args []string) int {
+	flags := newFlagSet("emit", "[options] --tokens|--ast|--c <file.bz>", "Prints an intermediate compilation stage of a source file.")
+	format := diagnosticsFormatFlag(flags)
+	tokens := flags.Bool("tokens", false, "print the scanned tokens")
+	nodes := flags.Bool("ast", false, "print the parsed syntax tree")
+	source := flags.Bool("c", false, "print the generated C source")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !validDiagnosticsFormat(*format) {
+		return usageError(flags, fmt.Sprintf("Unknown diagnostics format %s", *format))
+	}
+	if flags.NArg() != 1 {
+		return usageError(flags, "Expected exactly one source file")
+	}
+
+	selected := 0
+	for _, set := range []bool{*tokens, *nodes, *source} {
+		if set {
+			selected++
+		}
+	}
+	if selected != 1 {
+		return usageError(flags, "Expected exactly one of --tokens, --ast or --c")
+	}
+
+	path := flags.Arg(0)
+
+	switch {
+	case *tokens:
+		result, code := compile(path, stageScan, *format)
+		if code != out.ExOk {
+			return code
+		}
+		for _, tk := range result.tokens {
+			fmt.Println(tk.Stringify())
+		}
+	case *nodes:
+		result, code := compile(path, stageParse, *format)
+		if code != out.ExOk {
+			return code
+		}
+		for _, n := range result.nodes {
+			fmt.Println(n.String())
+		}
+	case *source:
+		result, code := compile(path, stageAnalyze, *format)
+		if code != out.ExOk {
+			return code
+		}
+		fmt.Print(clang.CompileToSource(result.file, result.nodes))
+	}
+
+	return out.ExOk
+}
+
+func replCommand(args []string) int {
+	flags := newFlagSet("repl", "[options]", "Reads statements, declarations and expressions, executes them with the tree-walking interpreter\nand prints the values of expressions. Enter :help for the commands of the REPL.")
+	historyPath := flags.String("history", repl.DefaultHistoryPath(), "file keeping the input history, empty to not keep it")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 0 {
+		return usageError(flags, "Expected no arguments")
+	}
+
+	repl.Run(os.Stdin, os.Stdout, os.Stderr, *historyPath)
+	return out.ExOk
+}
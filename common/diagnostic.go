@@ -0,0 +1,143 @@
+package common
+
+import (
+	"sort"
+)
+
+type Severity uint8
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "unknown"
+}
+
+// Span is a range of characters on a single line of a source file
+type Span struct {
+	Path     string
+	Position Position
+	Length   int
+}
+
+// Label attaches a message to a secondary span of a diagnostic
+type Label struct {
+	Span    Span
+	Message string
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	Span     Span
+	Labels   []Label
+	Hints    []string
+}
+
+func (d *Diagnostic) Label(span Span, message string) *Diagnostic {
+	d.Labels = append(d.Labels, Label{Span: span, Message: message})
+	return d
+}
+
+func (d *Diagnostic) Hint(hint string) *Diagnostic {
+	d.Hints = append(d.Hints, hint)
+	return d
+}
+
+func (d *Diagnostic) equals(other *Diagnostic) bool {
+	if d.Severity != other.Severity || d.Code != other.Code || d.Message != other.Message || d.Span != other.Span {
+		return false
+	}
+	if len(d.Labels) != len(other.Labels) || len(d.Hints) != len(other.Hints) {
+		return false
+	}
+	for i := range d.Labels {
+		if d.Labels[i] != other.Labels[i] {
+			return false
+		}
+	}
+	for i := range d.Hints {
+		if d.Hints[i] != other.Hints[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type DiagnosticBag struct {
+	Diagnostics []*Diagnostic
+}
+
+func InitDiagnosticBag() *DiagnosticBag {
+	return &DiagnosticBag{Diagnostics: make([]*Diagnostic, 0)}
+}
+
+func (b *DiagnosticBag) add(severity Severity, code string, message string, span Span) *Diagnostic {
+	diagnostic := &Diagnostic{Severity: severity, Code: code, Message: message, Span: span}
+	b.Diagnostics = append(b.Diagnostics, diagnostic)
+	return diagnostic
+}
+
+func (b *DiagnosticBag) Error(code string, message string, span Span) *Diagnostic {
+	return b.add(SeverityError, code, message, span)
+}
+
+func (b *DiagnosticBag) Warning(code string, message string, span Span) *Diagnostic {
+	return b.add(SeverityWarning, code, message, span)
+}
+
+func (b *DiagnosticBag) Merge(other *DiagnosticBag) {
+	b.Diagnostics = append(b.Diagnostics, other.Diagnostics...)
+}
+
+func (b *DiagnosticBag) HasErrors() bool {
+	for _, d := range b.Diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *DiagnosticBag) Empty() bool {
+	return len(b.Diagnostics) == 0
+}
+
+// Sort orders the diagnostics by their location in the source. Diagnostics at the same location keep their order.
+func (b *DiagnosticBag) Sort() {
+	sort.SliceStable(b.Diagnostics, func(i, j int) bool {
+		a := b.Diagnostics[i].Span
+		c := b.Diagnostics[j].Span
+		if a.Path != c.Path {
+			return a.Path < c.Path
+		}
+		return a.Position.Index < c.Position.Index
+	})
+}
+
+// Deduplicate removes diagnostics that are equal to a previous one
+func (b *DiagnosticBag) Deduplicate() {
+	unique := make([]*Diagnostic, 0, len(b.Diagnostics))
+	for _, d := range b.Diagnostics {
+		duplicate := false
+		for _, u := range unique {
+			if d.equals(u) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			unique = append(unique, d)
+		}
+	}
+	b.Diagnostics = unique
+}
@@ -11,11 +11,14 @@ class Entry:
 
 
 class Node:
-    def __init__(self, name, entries):
+    # Nodes with own_token get a Token field even if other entries are tokens
+    def __init__(self, name, entries, own_token=False):
         self.name = name
         self.entries = entries
 
         self.token_entry = 0
+        if own_token:
+            return
         for entry in entries:
             if entry.entry_type() == "scanner.Token":
                 self.token_entry = entry
@@ -198,29 +201,51 @@ nodes = {
     }),
     Decl("Struct", {
         Entry("Identifier", "string"),
-        Entry("ParentType", "string"),
-        Entry("ParamType", "[]string"),
-        Entry("ParamName", "[]string")
+        Entry("FieldType", "[]string"),
+        Entry("FieldName", "[]string"),
+        Entry("FieldToken", "[]scanner.Token"),
+        Entry("FieldTypeToken", "[]scanner.Token")
     }),
-    Stmt("Debug", {Entry("Expression", "Node")}),
+    Stmt("Debug", {Entry("Expression", "Node"), Entry("Type", "string")}),
     Stmt("Return", {Entry("Expression", "Node")}),
     Stmt("Continue", {}),
     Stmt("Break", {}),
     Stmt("Block", {Entry("Nodes", "[]Node")}),
     Stmt("Conditional", {Entry("Condition", "Node"), Entry("Statement", "Node"), Entry("ElseStatement", "Node")}),
     Stmt("While", {Entry("Condition", "Node"), Entry("Statement", "Node")}),
-    Stmt("Closure", {Entry("Block", "Node")}),
+    Stmt("For", {Entry("Initializer", "Node"), Entry("Condition", "Node"), Entry("Increment", "Node"), Entry("Statement", "Node")}),
+    Stmt("ForIn", {
+        Entry("Name", "scanner.Token"), Entry("Expression", "Node"), Entry("End", "Node"),
+        Entry("Statement", "Node"), Entry("Type", "string")
+    }, own_token=True),
+    Stmt("Closure", {Entry("Block", "Node"), Entry("End", "scanner.Token")}, own_token=True),
     Stmt("Expr", {Entry("Expression", "Node")}),
-    Expr("Assign", {Entry("Operator", "scanner.Token"), Entry("Name", "scanner.Token"), Entry("Value", "Node")}),
-    Expr("Set", {Entry("Expression", "Node"), Entry("Name", "scanner.Token"), Entry("Value", "Node")}),
-    Expr("Binary", {Entry("Operator", "scanner.Token"), Entry("Left", "Node"), Entry("Right", "Node")}),
-    Expr("Unary", {Entry("Operator", "scanner.Token"), Entry("Expression", "Node")}),
-    Expr("Call", {Entry("Expression", "Node"), Entry("Arguments", "[]Node")}),
+    Expr("Assign", {Entry("Operator", "scanner.Token"), Entry("Name", "scanner.Token"), Entry("Value", "Node"), Entry("Type", "string")}),
+    Expr("Set", {
+        Entry("Expression", "Node"), Entry("Name", "scanner.Token"), Entry("Operator", "scanner.Token"), Entry("Value", "Node"),
+        Entry("Type", "string")
+    }),
+    Expr("Binary", {Entry("Operator", "scanner.Token"), Entry("Left", "Node"), Entry("Right", "Node"), Entry("Type", "string")}),
+    Expr("Unary", {Entry("Operator", "scanner.Token"), Entry("Expression", "Node"), Entry("Type", "string")}),
+    Expr("Call", {Entry("Expression", "Node"), Entry("Arguments", "[]Node"), Entry("Type", "string")}),
+    Expr("Index", {Entry("Expression", "Node"), Entry("Index", "Node"), Entry("Type", "string")}),
+    Expr("SetIndex", {
+        Entry("Operator", "scanner.Token"), Entry("Expression", "Node"),
+        Entry("Index", "Node"), Entry("Value", "Node"), Entry("Type", "string")
+    }),
     Expr("Get", {Entry("Expression", "Node"), Entry("Name", "scanner.Token")}),
     Expr("IdentifierLit", {Entry("Name", "string")}),
-    Expr("IntegerLit", {Entry("Value", "string")}),
-    Expr("FloatingLit", {Entry("Value", "string")}),
+    Expr("IntegerLit", {Entry("Value", "string"), Entry("Type", "string")}),
+    Expr("FloatingLit", {Entry("Value", "string"), Entry("Type", "string")}),
     Expr("BooleanLit", {Entry("Value", "string")}),
+    Expr("StringLit", {Entry("Value", "string")}),
+    Expr("ArrayLit", {Entry("Values", "[]Node"), Entry("Type", "string")}),
+    Expr("StructLit", {Entry("Identifier", "string"), Entry("Fields", "[]scanner.Token"), Entry("Values", "[]Node")}),
+    Expr("Cast", {Entry("Expression", "Node"), Entry("TargetType", "string"), Entry("Type", "string")}),
+    Expr("Match", {
+        Entry("Value", "Node"), Entry("Patterns", "[][]Node"), Entry("Arms", "[]Node"),
+        Entry("Type", "string"), Entry("ResultType", "string")
+    }, own_token=True),
 }
 
 source = gen_source(nodes)
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"breeze/ast"
+	"breeze/clang"
+	"breeze/common"
+	"breeze/out"
+	"breeze/slow"
+	"breeze/vm"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGolden runs the programs in test/golden with every backend and compares their output with the .out files.
+// Compiled programs are built with the undefined behavior sanitizer, so they have to be correct C as well.
+func TestGolden(t *testing.T) {
+	sources, err := filepath.Glob(filepath.Join("test", "golden", "*.bz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) == 0 {
+		t.Fatal("no golden programs found")
+	}
+
+	for _, source := range sources {
+		source := source
+		name := strings.TrimSuffix(filepath.Base(source), ".bz")
+		expected, err := os.ReadFile(strings.TrimSuffix(source, ".bz") + ".out")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run(name+"/interp", func(t *testing.T) {
+			expectOutput(t, interpreted(t, source, slow.Run), string(expected))
+		})
+		t.Run(name+"/vm", func(t *testing.T) {
+			expectOutput(t, interpreted(t, source, vm.Run), string(expected))
+		})
+		t.Run(name+"/c", func(t *testing.T) {
+			expectOutput(t, compiled(t, source), string(expected))
+		})
+	}
+}
+
+// TestRuntimeErrors runs programs that fail with every backend and compares the error messages
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+	}{
+		{"division by zero", "let z = 0;\ndebug 1 / z;\n", "[error.bz:2:9] division by zero"},
+		{"unsigned remainder by zero", "let z: u8 = 0;\nlet x: u8 = 7;\nx %= z;\n", "[error.bz:3:1] division by zero"},
+		{"shift count", "let n = 64;\ndebug 1 << n;\n", "[error.bz:2:9] shift count out of range for 64 bits"},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "error.bz")
+		if err := os.WriteFile(path, []byte(test.source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		for backend, run := range map[string]func(common.SourceFile, []ast.Node, io.Writer) (int, error){"interp": slow.Run, "vm": vm.Run} {
+			t.Run(test.name+"/"+backend, func(t *testing.T) {
+				result := analyzed(t, path)
+				_, err := run(result.file, result.nodes, io.Discard)
+				if err == nil {
+					t.Fatal("expected runtime error")
+				}
+				expectOutput(t, err.Error(), test.message)
+			})
+		}
+		t.Run(test.name+"/c", func(t *testing.T) {
+			_, errors, err := executed(t, path)
+			if err == nil {
+				t.Fatal("expected runtime error")
+			}
+			expectOutput(t, errors, test.message+"\n")
+		})
+	}
+}
+
+func expectOutput(t *testing.T, output string, expected string) {
+	t.Helper()
+	if output != expected {
+		t.Errorf("unexpected output\n--- got\n%s--- expected\n%s", output, expected)
+	}
+}
+
+// analyzed returns the checked tree of the program at path, every backend gets a tree of its own
+func analyzed(t *testing.T, path string) unit {
+	t.Helper()
+	result, code := compile(path, stageAnalyze, formatHuman)
+	if code != out.ExOk {
+		t.Fatalf("%s does not compile, exit code %d", path, code)
+	}
+	return result
+}
+
+func interpreted(t *testing.T, path string, run func(common.SourceFile, []ast.Node, io.Writer) (int, error)) string {
+	result := analyzed(t, path)
+	output := bytes.Buffer{}
+	if _, err := run(result.file, result.nodes, &output); err != nil {
+		t.Fatalf("runtime error: %s", err.Error())
+	}
+	return output.String()
+}
+
+func compiled(t *testing.T, path string) string {
+	output, errors, err := executed(t, path)
+	if err != nil {
+		t.Fatalf("%s: %s", err.Error(), errors)
+	}
+	return output
+}
+
+// executed builds the program at path and returns what it writes to stdout and stderr
+func executed(t *testing.T, path string) (string, string, error) {
+	compiler, ok := testCompiler()
+	if !ok {
+		t.Skip("no C compiler found")
+	}
+
+	result := analyzed(t, path)
+	executable := filepath.Join(t.TempDir(), "program")
+	toolchain := clang.Toolchain{
+		Compiler: compiler,
+		Sanitize: "undefined",
+		CFlags:   []string{"-fno-sanitize-recover=undefined"},
+	}
+	if _, err := clang.Compile(executable, result.file, result.nodes, toolchain); err != nil {
+		if compilerError, ok := err.(*clang.CompilerError); ok {
+			t.Fatalf("%s\n%s", compilerError.Error(), compilerError.Output)
+		}
+		t.Fatal(err)
+	}
+
+	output := bytes.Buffer{}
+	errors := bytes.Buffer{}
+	cmd := exec.Command(executable)
+	cmd.Stdout = &output
+	cmd.Stderr = &errors
+	err := cmd.Run()
+	return output.String(), errors.String(), err
+}
+
+// testCompiler returns the default C compiler or a common one that is installed
+func testCompiler() (string, bool) {
+	for _, compiler := range []string{clang.DefaultCompiler(), "gcc", "cc"} {
+		if _, err := exec.LookPath(strings.Fields(compiler)[0]); err == nil {
+			return compiler, true
+		}
+	}
+	return "", false
+}
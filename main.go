@@ -2,101 +2,155 @@ package main
 
 import (
 	"breeze/analyzer"
-	"breeze/clang"
+	"breeze/ast"
 	"breeze/common"
 	"breeze/out"
 	"breeze/parser"
 	"breeze/scanner"
-	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
-	"time"
 )
 
-func main() {
-	file := common.InitSource("test/breeze.bz")
-
-	err := file.Validate()
-	if err != nil {
-		out.PrintErrorMessage(fmt.Sprintf("Could not validate path %s: %s", file.Path, err.Error()))
-		os.Exit(out.ExOsFile)
-	}
+const usage = `Usage: breeze <command> [options] <file.bz>
 
-	source, err := file.GetContent()
+Commands:
+  check   Scan, parse and analyze a source file
+  build   Compile a source file to an executable
+  run     Compile and execute a source file, forwarding arguments
+  emit    Print an intermediate compilation stage
+  repl    Evaluate input interactively
 
-	if err != nil {
-		out.PrintErrorMessage(fmt.Sprintf("Could not read %s", file.Path))
-		os.Exit(out.ExOsFile)
-	}
+Run 'breeze <command> --help' for the options of a command.
+`
 
-	tokens, hadError := scanner.Scan(&file, source)
+type stage uint8
 
-	if hadError {
-		out.PrintErrorMessage("Scanning phase failed")
-		os.Exit(out.ExDataErr)
-		return
-	}
+const (
+	stageScan stage = iota
+	stageParse
+	stageAnalyze
+)
 
-	for _, tk := range tokens {
-		fmt.Println(tk.Stringify())
-	}
+// Formats of the diagnostics report
+const (
+	formatHuman = "human"
+	formatJSON  = "json"
+	formatSARIF = "sarif"
+)
 
-	nodes, hadError := parser.ParseTokens(file, source, tokens)
+// unit holds everything the front end produced for a single source file.
+type unit struct {
+	file        common.SourceFile
+	source      string
+	tokens      []scanner.Token
+	nodes       []ast.Node
+	diagnostics *common.DiagnosticBag
+	format      string
+}
 
-	if hadError {
-		out.PrintErrorMessage("Parsing phase failed")
-		os.Exit(out.ExDataErr)
-		return
+// report renders the diagnostics of all phases that ran
+func (u *unit) report() {
+	u.diagnostics.Sort()
+	u.diagnostics.Deduplicate()
+
+	switch u.format {
+	case formatJSON:
+		out.ReportJSON(os.Stderr, u.diagnostics)
+	case formatSARIF:
+		out.ReportSARIF(os.Stderr, u.diagnostics)
+	default:
+		out.ReportDiagnostics(os.Stderr, u.source, u.diagnostics)
 	}
+}
 
-	for _, n := range nodes {
-		fmt.Println(n.String())
+func (u *unit) fail(message string) int {
+	u.report()
+	if u.format == formatHuman {
+		// Keep machine-readable output parsable
+		out.PrintErrorMessage(message)
 	}
+	return out.ExDataErr
+}
 
-	hadError = analyzer.Analyze(file, source, nodes)
+func main() {
+	if len(os.Args) < 2 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(out.ExUsage)
+	}
 
-	if hadError {
-		out.PrintErrorMessage("Static analyzing phase failed")
-		os.Exit(out.ExDataErr)
-		return
+	command := os.Args[1]
+	args := os.Args[2:]
+
+	switch command {
+	case "check":
+		os.Exit(checkCommand(args))
+	case "build":
+		os.Exit(buildCommand(args))
+	case "run":
+		os.Exit(runCommand(args))
+	case "emit":
+		os.Exit(emitCommand(args))
+	case "repl":
+		os.Exit(replCommand(args))
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		os.Exit(out.ExOk)
 	}
 
-	executablePath := "test/out"
-	compiled := clang.CompileClang(executablePath, file, nodes)
-	fmt.Println("-- COMPILED CLANG SOURCE --")
-	fmt.Println(compiled)
+	out.PrintErrorMessage(fmt.Sprintf("Unknown command %s", command))
+	_, _ = fmt.Fprint(os.Stderr, usage)
+	os.Exit(out.ExUsage)
+}
 
-	err = common.WriteFile("test/breeze.c", compiled)
+// compile runs the front end on path up to and including the given stage and reports its diagnostics in format.
+// On failure the error has already been reported and the exit code is returned.
+func compile(path string, until stage, format string) (unit, int) {
+	file := common.InitSource(path)
 
+	err := file.Validate()
 	if err != nil {
-		fmt.Println(err)
+		out.PrintErrorMessage(fmt.Sprintf("Could not validate path %s: %s", file.Path, err.Error()))
+		return unit{}, out.ExOsFile
 	}
 
-	fmt.Println("-- RUN EXECUTABLE --")
-
-	cmd := exec.Command(fmt.Sprintf("./%s", executablePath))
+	source, err := file.GetContent()
+	if err != nil {
+		out.PrintErrorMessage(fmt.Sprintf("Could not read %s", file.Path))
+		return unit{}, out.ExNoInput
+	}
 
-	var stdout = bytes.Buffer{}
-	var stderr = bytes.Buffer{}
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	result := unit{file: file, source: source, diagnostics: common.InitDiagnosticBag(), format: format}
 
-	timeStarted := time.Now().UnixMilli()
+	tokens, diagnostics := scanner.Scan(&file, source)
+	result.diagnostics.Merge(diagnostics)
+	if diagnostics.HasErrors() {
+		return result, result.fail("Scanning phase failed")
+	}
+	result.tokens = tokens
 
-	exitCode := 0
-	if err = cmd.Run(); err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		}
+	if until == stageScan {
+		result.report()
+		return result, out.ExOk
 	}
 
-	_, _ = fmt.Println(stderr.String())
-	_, _ = fmt.Println(stdout.String())
+	nodes, diagnostics := parser.ParseTokens(file, tokens)
+	result.diagnostics.Merge(diagnostics)
+	if diagnostics.HasErrors() {
+		return result, result.fail("Parsing phase failed")
+	}
+	result.nodes = nodes
 
-	timeDelta := time.Now().UnixMilli() - timeStarted
+	if until == stageParse {
+		result.report()
+		return result, out.ExOk
+	}
 
-	fmt.Println("Exit Code:", exitCode)
-	fmt.Println("Execution took:", timeDelta, "\bms")
+	diagnostics = analyzer.Analyze(file, nodes)
+	result.diagnostics.Merge(diagnostics)
+	if diagnostics.HasErrors() {
+		return result, result.fail("Static analyzing phase failed")
+	}
 
+	result.report()
+	return result, out.ExOk
 }
@@ -6,6 +6,11 @@ import (
 )
 
 func getMarker(length int, column int, icon rune) string {
+	// Empty lexemes like EOF are still marked
+	if length < 1 {
+		length = 1
+	}
+
 	marker := ""
 	for i := 0; i < column-1; i++ {
 		marker += " "
@@ -21,11 +26,19 @@ func markLexeme(line string, length int, column int, colors ...Color) string {
 	runes := []rune(line)
 	lineLen := len(runes)
 
-	if column < 0 || length < 0 || column+length > lineLen+1 {
+	if column < 0 || length < 0 {
 		fmt.Println("Lexeme marker out of bounds")
 		os.Exit(70)
 	}
 
+	// Markers at the end of the input, like the one of EOF, point behind the last character
+	if column > lineLen+1 {
+		column = lineLen + 1
+	}
+	if column+length > lineLen+1 {
+		length = lineLen + 1 - column
+	}
+
 	beforeEnd := column
 	if beforeEnd > 0 {
 		beforeEnd--
@@ -42,40 +55,25 @@ func markLexeme(line string, length int, column int, colors ...Color) string {
 
 	colorString := ""
 	for _, c := range colors {
-		colorString += string(c)
+		colorString += c.S()
 	}
 
-	return string(before) + colorString + string(lexeme) + string(ColorReset) + string(after)
+	return string(before) + colorString + string(lexeme) + ColorReset.S() + string(after)
 }
 
 func getLineBounds(source string, index int) (int, int) {
 	sourceLen := len(source)
+	if index > sourceLen {
+		index = sourceLen
+	}
 
 	lineStart := index
-	lineEnd := index
-
-	for {
-		if lineStart <= 0 || lineStart >= sourceLen {
-			break
-		}
-
-		if source[lineStart] == '\n' {
-			lineStart++ // Offset newline char
-			break
-		}
-
+	for lineStart > 0 && source[lineStart-1] != '\n' {
 		lineStart--
 	}
 
-	for {
-		if lineEnd < 0 || lineEnd >= sourceLen {
-			break
-		}
-
-		if source[lineEnd] == '\n' {
-			break
-		}
-
+	lineEnd := index
+	for lineEnd < sourceLen && source[lineEnd] != '\n' {
 		lineEnd++
 	}
 
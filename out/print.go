@@ -7,31 +7,43 @@ import (
 	"os"
 )
 
-func PrintErrorMessage(message string) {
-	_, err := fmt.Fprintf(os.Stderr, "%s%sERROR%s   %s%s%s\n", ColorRed.S(), ColorBold.S(), ColorReset.S(), ColorRed.S(), message, ColorReset.S())
+func printMessage(writer io.Writer, severity string, color Color, message string) {
+	_, err := fmt.Fprintf(writer, "%s%s%-7s%s %s%s%s\n", color.S(), ColorBold.S(), severity, ColorReset.S(), color.S(), message, ColorReset.S())
 	if err != nil {
 		os.Exit(ExIoErr)
 		return
 	}
 }
 
-func PrintHintMessage(hint string, color Color) {
-	_, err := fmt.Fprintf(os.Stderr, "      | %s%s%s\n", color.S(), hint, ColorReset.S())
+func PrintErrorMessage(message string) {
+	printMessage(os.Stderr, "ERROR", ColorRed, message)
+}
+
+func printHintMessage(writer io.Writer, hint string, color Color) {
+	_, err := fmt.Fprintf(writer, "      | %s%s%s\n", color.S(), hint, ColorReset.S())
 	if err != nil {
 		os.Exit(ExIoErr)
 		return
 	}
 }
 
-func PrintErrorSource(path string, position common.Position) {
+func PrintHintMessage(hint string, color Color) {
+	printHintMessage(os.Stderr, hint, color)
+}
+
+func printErrorSource(writer io.Writer, path string, position common.Position) {
 	// →
-	_, err := fmt.Fprintf(os.Stderr, "%s      → %s:%d:%d%s\n", ColorWhite.S(), path, position.Line, position.Column, ColorReset.S())
+	_, err := fmt.Fprintf(writer, "%s      → %s:%d:%d%s\n", ColorWhite.S(), path, position.Line, position.Column, ColorReset.S())
 	if err != nil {
 		os.Exit(ExIoErr)
 		return
 	}
 }
 
+func PrintErrorSource(path string, position common.Position) {
+	printErrorSource(os.Stderr, path, position)
+}
+
 func printLineString(writer io.Writer, line int, lineString string) {
 	_, err := fmt.Fprintf(writer, "%5d | %s\n", line, lineString)
 	if err != nil {
@@ -51,7 +63,7 @@ func PrintMarkedLine(writer io.Writer, source string, length int, position commo
 	printLineString(writer, position.Line, markedLine)
 
 	marker := getMarker(length, position.Column, icon)
-	_, err := fmt.Fprintf(writer, "      | %s%s%s\n", color, marker, ColorReset.S())
+	_, err := fmt.Fprintf(writer, "      | %s%s%s\n", color.S(), marker, ColorReset.S())
 	if err != nil {
 		os.Exit(ExIoErr)
 		return
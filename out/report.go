@@ -0,0 +1,50 @@
+package out
+
+import (
+	"breeze/common"
+	"fmt"
+	"io"
+)
+
+// ReportDiagnostics renders every diagnostic of bag in a human-readable form
+func ReportDiagnostics(writer io.Writer, source string, bag *common.DiagnosticBag) {
+	for _, diagnostic := range bag.Diagnostics {
+		ReportDiagnostic(writer, source, diagnostic)
+	}
+}
+
+func ReportDiagnostic(writer io.Writer, source string, diagnostic *common.Diagnostic) {
+	severity := "ERROR"
+	color := ColorRed
+	if diagnostic.Severity == common.SeverityWarning {
+		severity = "WARNING"
+		color = ColorYellow
+	}
+
+	message := diagnostic.Message
+	if len(diagnostic.Code) > 0 {
+		message = fmt.Sprintf("%s [%s]", message, diagnostic.Code)
+	}
+
+	printMessage(writer, severity, color, message)
+	printSpan(writer, source, diagnostic.Span, color, '^')
+
+	for _, hint := range diagnostic.Hints {
+		printHintMessage(writer, hint, color)
+	}
+
+	for _, label := range diagnostic.Labels {
+		printSpan(writer, source, label.Span, ColorBlue, '-')
+		printHintMessage(writer, label.Message, ColorBlue)
+	}
+}
+
+func printSpan(writer io.Writer, source string, span common.Span, color Color, icon rune) {
+	if len(span.Path) == 0 {
+		// Builtin declarations have no source location
+		return
+	}
+
+	printErrorSource(writer, span.Path, span.Position)
+	PrintMarkedLine(writer, source, span.Length, span.Position, color, icon)
+}
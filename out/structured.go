@@ -0,0 +1,213 @@
+package out
+
+import (
+	"breeze/common"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type jsonLabel struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Length  int    `json:"length"`
+	Message string `json:"message"`
+}
+
+type jsonDiagnostic struct {
+	File     string      `json:"file"`
+	Line     int         `json:"line"`
+	Column   int         `json:"column"`
+	Length   int         `json:"length"`
+	Severity string      `json:"severity"`
+	Code     string      `json:"code"`
+	Message  string      `json:"message"`
+	Hints    []string    `json:"hints"`
+	Labels   []jsonLabel `json:"labels"`
+}
+
+// ReportJSON writes one JSON object per line for every diagnostic of bag
+func ReportJSON(writer io.Writer, bag *common.DiagnosticBag) {
+	encoder := json.NewEncoder(writer)
+
+	for _, d := range bag.Diagnostics {
+		labels := make([]jsonLabel, 0, len(d.Labels))
+		for _, label := range d.Labels {
+			labels = append(labels, jsonLabel{
+				File:    label.Span.Path,
+				Line:    label.Span.Position.Line,
+				Column:  label.Span.Position.Column,
+				Length:  label.Span.Length,
+				Message: label.Message,
+			})
+		}
+
+		hints := d.Hints
+		if hints == nil {
+			hints = make([]string, 0)
+		}
+
+		err := encoder.Encode(jsonDiagnostic{
+			File:     d.Span.Path,
+			Line:     d.Span.Position.Line,
+			Column:   d.Span.Position.Column,
+			Length:   d.Span.Length,
+			Severity: d.Severity.String(),
+			Code:     d.Code,
+			Message:  d.Message,
+			Hints:    hints,
+			Labels:   labels,
+		})
+		if err != nil {
+			os.Exit(ExIoErr)
+			return
+		}
+	}
+}
+
+// Subset of the SARIF 2.1.0 object model, see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id string `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifLocation struct {
+	Id               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	Uri       string `json:"uri"`
+	UriBaseId string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func sarifArtifact(path string) sarifArtifactLocation {
+	// Code scanning dashboards expect paths relative to the checkout
+	workingDirectory, err := os.Getwd()
+	if err == nil {
+		relative, err := filepath.Rel(workingDirectory, path)
+		if err == nil && !strings.HasPrefix(relative, "..") {
+			return sarifArtifactLocation{Uri: filepath.ToSlash(relative), UriBaseId: "%SRCROOT%"}
+		}
+	}
+	return sarifArtifactLocation{Uri: "file://" + filepath.ToSlash(path)}
+}
+
+func sarifPhysical(span common.Span) sarifPhysicalLocation {
+	return sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifact(span.Path),
+		Region: sarifRegion{
+			StartLine:   span.Position.Line,
+			StartColumn: span.Position.Column,
+			EndColumn:   span.Position.Column + span.Length,
+		},
+	}
+}
+
+// ReportSARIF writes a SARIF 2.1.0 log containing every diagnostic of bag
+func ReportSARIF(writer io.Writer, bag *common.DiagnosticBag) {
+	rules := make([]sarifRule, 0)
+	knownRules := make(map[string]bool)
+	results := make([]sarifResult, 0, len(bag.Diagnostics))
+
+	for _, d := range bag.Diagnostics {
+		if !knownRules[d.Code] {
+			knownRules[d.Code] = true
+			rules = append(rules, sarifRule{Id: d.Code})
+		}
+
+		// Hints are part of the message, as dashboards only show the message
+		text := d.Message
+		for _, hint := range d.Hints {
+			text += "\n" + hint
+		}
+
+		related := make([]sarifLocation, 0, len(d.Labels))
+		for i, label := range d.Labels {
+			if len(label.Span.Path) == 0 {
+				// Builtin declarations have no source location
+				text += "\n" + label.Message
+				continue
+			}
+
+			id := i
+			related = append(related, sarifLocation{
+				Id:               &id,
+				PhysicalLocation: sarifPhysical(label.Span),
+				Message:          &sarifMessage{Text: label.Message},
+			})
+		}
+
+		results = append(results, sarifResult{
+			RuleId:           d.Code,
+			Level:            d.Severity.String(),
+			Message:          sarifMessage{Text: text},
+			Locations:        []sarifLocation{{PhysicalLocation: sarifPhysical(d.Span)}},
+			RelatedLocations: related,
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "breeze", Rules: rules}},
+			Results: results,
+		}},
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(log)
+	if err != nil {
+		os.Exit(ExIoErr)
+		return
+	}
+}
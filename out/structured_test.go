@@ -0,0 +1,106 @@
+package out
+
+import (
+	"breeze/common"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func span(path string, line int, column int, length int) common.Span {
+	return common.Span{Path: path, Position: common.Position{Line: line, Column: column}, Length: length}
+}
+
+func TestReportJSON(t *testing.T) {
+	bag := common.InitDiagnosticBag()
+	bag.Error("BZ0303", "Unexpected condition type", span("test.bz", 2, 7, 1)).
+		Label(span("test.bz", 2, 1, 5), "Expected bool").
+		Hint("Conditions are bool")
+	bag.Warning("BZ0311", "Unreachable code", span("test.bz", 4, 5, 5))
+
+	output := bytes.Buffer{}
+	ReportJSON(&output, bag)
+
+	expected := `{"file":"test.bz","line":2,"column":7,"length":1,"severity":"error","code":"BZ0303","message":"Unexpected condition type","hints":["Conditions are bool"],"labels":[{"file":"test.bz","line":2,"column":1,"length":5,"message":"Expected bool"}]}
+{"file":"test.bz","line":4,"column":5,"length":5,"severity":"warning","code":"BZ0311","message":"Unreachable code","hints":[],"labels":[]}
+`
+	if output.String() != expected {
+		t.Errorf("unexpected output\n--- got\n%s--- expected\n%s", output.String(), expected)
+	}
+}
+
+func TestReportSARIF(t *testing.T) {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inside := filepath.Join(workingDirectory, "src", "test.bz")
+	outside := filepath.Join(filepath.Dir(workingDirectory), "other.bz")
+
+	bag := common.InitDiagnosticBag()
+	bag.Error("BZ0302", "Function f already declared", span(inside, 3, 4, 1)).
+		Label(span(outside, 1, 4, 1), "Declared here").
+		Label(span("", 0, 0, 0), "Builtin function").
+		Hint("Rename the function")
+
+	output := bytes.Buffer{}
+	ReportSARIF(&output, bag)
+
+	var log any
+	if err := json.Unmarshal(output.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     []any
+		expected any
+	}{
+		{[]any{"version"}, "2.1.0"},
+		{[]any{"runs", 0, "tool", "driver", "name"}, "breeze"},
+		{[]any{"runs", 0, "tool", "driver", "rules", 0, "id"}, "BZ0302"},
+		{[]any{"runs", 0, "results", 0, "ruleId"}, "BZ0302"},
+		{[]any{"runs", 0, "results", 0, "level"}, "error"},
+		// Hints and labels without location are part of the message
+		{[]any{"runs", 0, "results", 0, "message", "text"}, "Function f already declared\nRename the function\nBuiltin function"},
+		{[]any{"runs", 0, "results", 0, "locations", 0, "physicalLocation", "artifactLocation", "uri"}, "src/test.bz"},
+		{[]any{"runs", 0, "results", 0, "locations", 0, "physicalLocation", "artifactLocation", "uriBaseId"}, "%SRCROOT%"},
+		{[]any{"runs", 0, "results", 0, "locations", 0, "physicalLocation", "region", "startLine"}, 3.0},
+		{[]any{"runs", 0, "results", 0, "locations", 0, "physicalLocation", "region", "startColumn"}, 4.0},
+		{[]any{"runs", 0, "results", 0, "locations", 0, "physicalLocation", "region", "endColumn"}, 5.0},
+		{[]any{"runs", 0, "results", 0, "relatedLocations", 0, "id"}, 0.0},
+		{[]any{"runs", 0, "results", 0, "relatedLocations", 0, "message", "text"}, "Declared here"},
+		{[]any{"runs", 0, "results", 0, "relatedLocations", 0, "physicalLocation", "artifactLocation", "uri"}, "file://" + filepath.ToSlash(outside)},
+		{[]any{"runs", 0, "results", 0, "relatedLocations", 0, "physicalLocation", "artifactLocation", "uriBaseId"}, nil},
+		{[]any{"runs", 0, "results", 0, "relatedLocations", 1}, nil},
+	}
+	for _, test := range tests {
+		if value := member(log, test.path); value != test.expected {
+			t.Errorf("expected %v at %v, got %v", test.expected, test.path, value)
+		}
+	}
+}
+
+// member returns the value at path of object keys and array indexes in a decoded JSON value, or nil if it is missing
+func member(value any, path []any) any {
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			name, ok := key.(string)
+			if !ok {
+				return nil
+			}
+			value = v[name]
+		case []any:
+			index, ok := key.(int)
+			if !ok || index >= len(v) {
+				return nil
+			}
+			value = v[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
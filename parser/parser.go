@@ -3,15 +3,20 @@ package parser
 import (
 	"breeze/ast"
 	"breeze/common"
-	"breeze/out"
 	"breeze/scanner"
-	"os"
+	"fmt"
+	"strconv"
 )
 
 type tokenParser struct {
 	tokens []scanner.Token
 	length int
 	cursor int
+	// Errors the parser recovers from without an error node
+	file        common.SourceFile
+	diagnostics *common.DiagnosticBag
+	// Struct literals are ambiguous with blocks after conditions like: if a == b { ... }
+	noStructLiteral bool
 }
 
 var emptyToken = scanner.Token{Id: scanner.EOF, Lexeme: "empty token", Position: common.InitPosition()}
@@ -21,11 +26,16 @@ func (p *tokenParser) isDone() bool {
 }
 
 func (p *tokenParser) advance() scanner.Token {
+	token := p.peek()
 	p.cursor++
-	return p.tokens[p.cursor-1]
+	return token
 }
 
+// peek returns the EOF token at the end of the tokens, even if it has been advanced over
 func (p *tokenParser) peek() scanner.Token {
+	if p.cursor >= p.length {
+		return p.tokens[p.length-1]
+	}
 	return p.tokens[p.cursor]
 }
 
@@ -40,6 +50,9 @@ func (p *tokenParser) peekPrevious() scanner.Token {
 	if p.cursor <= 0 {
 		return emptyToken
 	}
+	if p.cursor > p.length {
+		return p.tokens[p.length-1]
+	}
 	return p.tokens[p.cursor-1]
 }
 
@@ -47,11 +60,13 @@ func (p *tokenParser) expect(id scanner.TokenId) bool {
 	return p.peek().Id == id
 }
 
-func initParser(tokens []scanner.Token) tokenParser {
+func initParser(file common.SourceFile, tokens []scanner.Token) tokenParser {
 	return tokenParser{
-		tokens: tokens,
-		length: len(tokens),
-		cursor: 0,
+		tokens:      tokens,
+		length:      len(tokens),
+		cursor:      0,
+		file:        file,
+		diagnostics: common.InitDiagnosticBag(),
 	}
 }
 
@@ -70,9 +85,12 @@ func synchronize(parser *tokenParser, id scanner.TokenId) {
 	}
 }
 
-func ParseTokens(file common.SourceFile, source string, tokens []scanner.Token) ([]ast.Node, bool) {
-	parser := initParser(tokens)
-	hadError := false
+// codeSyntax is the diagnostic code of all errors of the parsing phase
+const codeSyntax = "BZ0201"
+
+func ParseTokens(file common.SourceFile, tokens []scanner.Token) ([]ast.Node, *common.DiagnosticBag) {
+	parser := initParser(file, tokens)
+	diagnostics := parser.diagnostics
 	var nodes []ast.Node
 
 	for {
@@ -83,18 +101,14 @@ func ParseTokens(file common.SourceFile, source string, tokens []scanner.Token)
 		node := declaration(&parser)
 
 		if node.GetType() == ast.Err {
-			hadError = true
 			errNode := node.(*ast.ErrNode)
 			token := node.GetToken()
-			pos := node.GetToken().Position
 
-			out.PrintErrorMessage(errNode.Message)
-			out.PrintErrorSource(file.Path, pos)
-			out.PrintMarkedLine(os.Stderr, source, token.LexemeLength(), pos, out.ColorRed, '^')
+			diagnostic := diagnostics.Error(codeSyntax, errNode.Message, token.Span(file))
 
 			hintLen := len(errNode.Hint)
 			if hintLen > 0 {
-				out.PrintHintMessage(errNode.Hint, out.ColorRed)
+				diagnostic.Hint(errNode.Hint)
 			}
 
 			// Synchronize to ;
@@ -106,7 +120,7 @@ func ParseTokens(file common.SourceFile, source string, tokens []scanner.Token)
 		nodes = append(nodes, node)
 	}
 
-	return nodes, hadError
+	return nodes, diagnostics
 }
 
 func expectSemicolon(parser *tokenParser, result ast.Node) ast.Node {
@@ -125,6 +139,8 @@ func declaration(parser *tokenParser) ast.Node {
 		return let(parser)
 	case scanner.Fn:
 		return fn(parser)
+	case scanner.Struct:
+		return structDecl(parser)
 	}
 
 	return statement(parser)
@@ -144,11 +160,10 @@ func let(parser *tokenParser) ast.Node {
 		// Consume :
 		_ = parser.advance()
 
-		if parser.peek().Id != scanner.Identifier {
-			return err(identifierToken, "Expected identifier in let type declaration", "")
+		lexeme, errNode := typeName(parser)
+		if errNode != nil {
+			return errNode
 		}
-
-		lexeme := parser.advance().Lexeme
 		varType = lexeme
 	}
 
@@ -199,12 +214,11 @@ func fn(parser *tokenParser) ast.Node {
 			break
 		}
 
-		if parser.peek().Id != scanner.Identifier {
-			return err(parser.peek(), "Expected identifier as parameter type", "")
+		paramType, errNode := typeName(parser)
+		if errNode != nil {
+			return errNode
 		}
 
-		paramType := parser.advance().Lexeme
-
 		if parser.peek().Id != scanner.Identifier {
 			return err(parser.peek(), "Expected identifier as parameter name", "")
 		}
@@ -228,8 +242,24 @@ func fn(parser *tokenParser) ast.Node {
 	}
 
 	returnType := ""
-	if parser.peek().Id == scanner.Identifier {
-		returnType = parser.advance().Lexeme
+	if parser.peek().Id == scanner.Identifier || parser.peek().Id == scanner.OpenBracket {
+		// Return types used to follow the parameters directly. Keep parsing to not report the body as well.
+		typeToken := parser.peek()
+		lexeme, errNode := typeName(parser)
+		if errNode != nil {
+			return errNode
+		}
+		parser.diagnostics.Error(codeSyntax, "Expected -> before return type", typeToken.Span(parser.file)).Hint(fmt.Sprintf("Declare return types like: fn %s(...) -> %s", fnName, lexeme))
+		returnType = lexeme
+	} else if parser.peek().Id == scanner.Arrow {
+		// Consume ->
+		_ = parser.advance()
+
+		lexeme, errNode := typeName(parser)
+		if errNode != nil {
+			return errNode
+		}
+		returnType = lexeme
 	}
 
 	cl := closure(parser)
@@ -237,6 +267,126 @@ func fn(parser *tokenParser) ast.Node {
 	return &ast.FunctionDecl{Token: keyword, Closure: cl, Identifier: fnName, ReturnType: returnType, ParamName: paramNames, ParamType: paramTypes}
 }
 
+// typeName parses a type like int, []int or [int; 4] and returns its name
+// namedType returns the token of the name in the tokens of a type, like Point in [[]Point; 2]
+func namedType(tokens []scanner.Token) scanner.Token {
+	for _, token := range tokens {
+		if token.Id == scanner.Identifier {
+			return token
+		}
+	}
+	return tokens[0]
+}
+
+func typeName(parser *tokenParser) (string, ast.Node) {
+	current := parser.advance()
+
+	switch current.Id {
+	case scanner.Identifier:
+		return current.Lexeme, nil
+	case scanner.OpenBracket:
+		break
+	default:
+		return "", err(current, "Expected type", "Types look like int, []int or [int; 4]")
+	}
+
+	if parser.peek().Id == scanner.CloseBracket {
+		// Consume ]
+		_ = parser.advance()
+
+		element, errNode := typeName(parser)
+		if errNode != nil {
+			return "", errNode
+		}
+		return ast.SliceTypeName(element), nil
+	}
+
+	element, errNode := typeName(parser)
+	if errNode != nil {
+		return "", errNode
+	}
+
+	if parser.advance().Id != scanner.Semicolon {
+		return "", err(parser.peekPrevious(), "Expected semicolon in array type", "Declare arrays like [int; 4] and slices like []int")
+	}
+
+	lengthToken := parser.advance()
+	if lengthToken.Id != scanner.Integer {
+		return "", err(lengthToken, "Expected integer as array length", "")
+	}
+
+	length, convErr := strconv.Atoi(lengthToken.Value)
+	if convErr != nil || length <= 0 {
+		return "", err(lengthToken, "Invalid array length", "Arrays need a positive length")
+	}
+
+	if parser.advance().Id != scanner.CloseBracket {
+		return "", err(parser.peekPrevious(), "Expected closing bracket in array type", "")
+	}
+
+	return ast.ArrayTypeName(element, length), nil
+}
+
+func structDecl(parser *tokenParser) ast.Node {
+	keyword := parser.advance()
+
+	identifierToken := parser.advance()
+	if identifierToken.Id != scanner.Identifier {
+		return err(identifierToken, "Expected identifier in struct name declaration", "")
+	}
+	structName := identifierToken.Lexeme
+
+	if !parser.expect(scanner.OpenBrace) {
+		return err(parser.peek(), "Expected open brace in struct declaration", "")
+	}
+	_ = parser.advance()
+
+	fieldTypes := make([]string, 0)
+	fieldNames := make([]string, 0)
+	fieldTokens := make([]scanner.Token, 0)
+	fieldTypeTokens := make([]scanner.Token, 0)
+
+	for {
+		if parser.peek().Id == scanner.CloseBrace {
+			break
+		}
+
+		if parser.peek().Id != scanner.Identifier {
+			return err(parser.peek(), "Expected identifier as field name", "")
+		}
+
+		fieldToken := parser.advance()
+
+		if parser.advance().Id != scanner.Colon {
+			return err(parser.peekPrevious(), "Expected colon after field name", "Declare fields like x: int")
+		}
+
+		start := parser.cursor
+		fieldType, errNode := typeName(parser)
+		if errNode != nil {
+			return errNode
+		}
+
+		fieldTypes = append(fieldTypes, fieldType)
+		fieldNames = append(fieldNames, fieldToken.Lexeme)
+		fieldTokens = append(fieldTokens, fieldToken)
+		fieldTypeTokens = append(fieldTypeTokens, namedType(parser.tokens[start:parser.cursor]))
+
+		if parser.peek().Id == scanner.CloseBrace {
+			break
+		}
+
+		if parser.advance().Id != scanner.Comma {
+			return err(parser.peekPrevious(), "Expected comma as field separator", "")
+		}
+	}
+
+	// Consume }
+	_ = parser.advance()
+
+	return &ast.StructDecl{Token: keyword, Identifier: structName, FieldType: fieldTypes, FieldName: fieldNames, FieldToken: fieldTokens, FieldTypeToken: fieldTypeTokens}
+}
+
 func statement(parser *tokenParser) ast.Node {
 	current := parser.peek()
 
@@ -249,12 +399,16 @@ func statement(parser *tokenParser) ast.Node {
 		return debug(parser)
 	case scanner.While:
 		return whileLoop(parser)
+	case scanner.For:
+		return forLoop(parser)
 	case scanner.Return:
 		return returnStmt(parser)
 	case scanner.Continue:
 		return expectSemicolon(parser, &ast.ContinueStmt{Token: parser.advance()})
 	case scanner.Break:
 		return expectSemicolon(parser, &ast.BreakStmt{Token: parser.advance()})
+	case scanner.Match:
+		return matchStatement(parser)
 	}
 
 	// Parse expression statement
@@ -267,11 +421,25 @@ func statement(parser *tokenParser) ast.Node {
 	return expectSemicolon(parser, result)
 }
 
+// matchStatement parses a match that is not part of an expression. Like blocks it ends with }, so the semicolon is
+// optional.
+func matchStatement(parser *tokenParser) ast.Node {
+	expr := match(parser, parser.advance())
+	if expr.GetId() == ast.ErrId {
+		return expr
+	}
+
+	if parser.peek().Id == scanner.Semicolon {
+		_ = parser.advance()
+	}
+	return &ast.ExprStmt{Token: expr.GetToken(), Expression: expr}
+}
+
 func conditional(parser *tokenParser) ast.Node {
 	// Consume if
 	keyword := parser.advance()
 
-	condition := expression(parser)
+	condition := conditionExpression(parser)
 	if condition.GetId() == ast.ErrId {
 		return condition
 	}
@@ -299,13 +467,16 @@ func closure(parser *tokenParser) ast.Node {
 
 	nodes := make([]ast.Node, 0)
 
+	// EOF if the closure is not closed
+	end := parser.peek()
 	for {
 		if parser.isDone() {
+			end = parser.peek()
 			break
 		}
 
 		if parser.peek().Id == scanner.CloseBrace {
-			parser.advance()
+			end = parser.advance()
 			break
 		}
 
@@ -314,7 +485,7 @@ func closure(parser *tokenParser) ast.Node {
 	}
 
 	block := &ast.BlockStmt{Token: keyword, Nodes: nodes}
-	return &ast.ClosureStmt{Token: keyword, Block: block}
+	return &ast.ClosureStmt{Token: keyword, Block: block, End: end}
 }
 
 func debug(parser *tokenParser) ast.Node {
@@ -334,7 +505,7 @@ func whileLoop(parser *tokenParser) ast.Node {
 		// Allow for infinite while loop: while { ... }
 		condition = &ast.BooleanLitExpr{Token: keyword, Value: "true"}
 	} else {
-		condition = expression(parser)
+		condition = conditionExpression(parser)
 		if condition.GetId() == ast.ErrId {
 			return condition
 		}
@@ -348,6 +519,87 @@ func whileLoop(parser *tokenParser) ast.Node {
 	return &ast.WhileStmt{Token: keyword, Condition: condition, Statement: stmt}
 }
 
+// forLoop parses the loops: for x in collection { }, for i in start..end { } and for let i = 0; i < n; i += 1 { }
+func forLoop(parser *tokenParser) ast.Node {
+	keyword := parser.advance()
+
+	if parser.peek().Id == scanner.Identifier && parser.peekNext().Id == scanner.In {
+		return forInLoop(parser, keyword)
+	}
+
+	// Every part of the header is optional, for ;; { } loops endlessly
+	var initializer ast.Node
+	switch parser.peek().Id {
+	case scanner.Semicolon:
+		_ = parser.advance()
+	case scanner.Let:
+		initializer = let(parser)
+	default:
+		expr := expression(parser)
+		if expr.GetId() == ast.ErrId {
+			return expr
+		}
+		initializer = expectSemicolon(parser, &ast.ExprStmt{Token: expr.GetToken(), Expression: expr})
+	}
+	if initializer != nil && initializer.GetId() == ast.ErrId {
+		return initializer
+	}
+
+	var condition ast.Node
+	if parser.peek().Id != scanner.Semicolon {
+		condition = conditionExpression(parser)
+		if condition.GetId() == ast.ErrId {
+			return condition
+		}
+	}
+	if parser.advance().Id != scanner.Semicolon {
+		return err(parser.peekPrevious(), "Expected ; after loop condition", "Separate the parts of the loop like: for let i = 0; i < n; i += 1 { }")
+	}
+
+	var increment ast.Node
+	if parser.peek().Id != scanner.OpenBrace {
+		increment = conditionExpression(parser)
+		if increment.GetId() == ast.ErrId {
+			return increment
+		}
+	}
+
+	stmt := declaration(parser)
+	if stmt.GetId() == ast.ErrId {
+		return stmt
+	}
+
+	return &ast.ForStmt{Token: keyword, Initializer: initializer, Condition: condition, Increment: increment, Statement: stmt}
+}
+
+func forInLoop(parser *tokenParser, keyword scanner.Token) ast.Node {
+	name := parser.advance()
+	// Consume in
+	_ = parser.advance()
+
+	expr := conditionExpression(parser)
+	if expr.GetId() == ast.ErrId {
+		return expr
+	}
+
+	// The end of a range is exclusive
+	var end ast.Node
+	if parser.peek().Id == scanner.DotDot {
+		_ = parser.advance()
+		end = conditionExpression(parser)
+		if end.GetId() == ast.ErrId {
+			return end
+		}
+	}
+
+	stmt := declaration(parser)
+	if stmt.GetId() == ast.ErrId {
+		return stmt
+	}
+
+	return &ast.ForInStmt{Token: keyword, Name: name, Expression: expr, End: end, Statement: stmt}
+}
+
 func returnStmt(parser *tokenParser) ast.Node {
 	keyword := parser.advance()
 
@@ -362,10 +614,35 @@ func returnStmt(parser *tokenParser) ast.Node {
 	return expectSemicolon(parser, &ast.ReturnStmt{Token: keyword, Expression: expr})
 }
 
+// Binary operators from the loosest to the tightest binding, operators of one level are left associative:
+//
+//	= += -= *= /= %= &= |= ^= <<= >>=  (right associative)
+//	||
+//	&&
+//	== !=
+//	< > <= >=
+//	|
+//	^
+//	&
+//	<< >>
+//	+ -
+//	* / %
+//	as
+//
+// Bitwise operators bind tighter than comparisons, so a & mask == 0 compares the masked value.
 func expression(parser *tokenParser) ast.Node {
 	return assign(parser)
 }
 
+// conditionExpression parses an expression that is followed by a block
+func conditionExpression(parser *tokenParser) ast.Node {
+	prev := parser.noStructLiteral
+	parser.noStructLiteral = true
+	expr := expression(parser)
+	parser.noStructLiteral = prev
+	return expr
+}
+
 func assign(parser *tokenParser) ast.Node {
 	expr := logOr(parser)
 	if expr.GetId() == ast.ErrId {
@@ -374,7 +651,7 @@ func assign(parser *tokenParser) ast.Node {
 
 	var current = parser.peek()
 
-	if current.Id != scanner.Equals && current.Id != scanner.PlusEquals && current.Id != scanner.MinusEquals && current.Id != scanner.StarEquals && current.Id != scanner.SlashEquals {
+	if _, isCompound := scanner.CompoundOperator(current.Id); current.Id != scanner.Equals && !isCompound {
 		return expr
 	}
 
@@ -395,6 +672,26 @@ func assign(parser *tokenParser) ast.Node {
 		}
 	}
 
+	if expr.GetId() == ast.GetId {
+		get := expr.(*ast.GetExpr)
+		return &ast.SetExpr{
+			Operator:   operator,
+			Expression: get.Expression,
+			Name:       get.Name,
+			Value:      right,
+		}
+	}
+
+	if expr.GetId() == ast.IndexId {
+		index := expr.(*ast.IndexExpr)
+		return &ast.SetIndexExpr{
+			Operator:   operator,
+			Expression: index.Expression,
+			Index:      index.Index,
+			Value:      right,
+		}
+	}
+
 	return err(operator, "Unsupported assign operation on token", "Expected identifier TODO: or call")
 }
 
@@ -465,7 +762,7 @@ func equality(parser *tokenParser) ast.Node {
 }
 
 func comparison(parser *tokenParser) ast.Node {
-	left := add(parser)
+	left := bitOr(parser)
 
 	for {
 		if parser.isDone() {
@@ -477,6 +774,94 @@ func comparison(parser *tokenParser) ast.Node {
 			break
 		}
 
+		operator := parser.advance()
+		right := bitOr(parser)
+
+		left = &ast.BinaryExpr{Operator: operator, Left: left, Right: right}
+	}
+
+	return left
+}
+
+func bitOr(parser *tokenParser) ast.Node {
+	left := bitXor(parser)
+
+	for {
+		if parser.isDone() {
+			break
+		}
+
+		current := parser.peek()
+		if current.Id != scanner.Pipe {
+			break
+		}
+
+		operator := parser.advance()
+		right := bitXor(parser)
+
+		left = &ast.BinaryExpr{Operator: operator, Left: left, Right: right}
+	}
+
+	return left
+}
+
+func bitXor(parser *tokenParser) ast.Node {
+	left := bitAnd(parser)
+
+	for {
+		if parser.isDone() {
+			break
+		}
+
+		current := parser.peek()
+		if current.Id != scanner.Caret {
+			break
+		}
+
+		operator := parser.advance()
+		right := bitAnd(parser)
+
+		left = &ast.BinaryExpr{Operator: operator, Left: left, Right: right}
+	}
+
+	return left
+}
+
+func bitAnd(parser *tokenParser) ast.Node {
+	left := shift(parser)
+
+	for {
+		if parser.isDone() {
+			break
+		}
+
+		current := parser.peek()
+		if current.Id != scanner.And {
+			break
+		}
+
+		operator := parser.advance()
+		right := shift(parser)
+
+		left = &ast.BinaryExpr{Operator: operator, Left: left, Right: right}
+	}
+
+	return left
+}
+
+func shift(parser *tokenParser) ast.Node {
+	left := add(parser)
+
+	for {
+		if parser.isDone() {
+			break
+		}
+
+		current := parser.peek()
+		if current.Id != scanner.LowerLower && current.Id != scanner.GreaterGreater {
+			break
+		}
+
 		operator := parser.advance()
 		right := add(parser)
 
@@ -518,7 +903,7 @@ func add(parser *tokenParser) ast.Node {
 }
 
 func multiply(parser *tokenParser) ast.Node {
-	left := unary(parser)
+	left := cast(parser)
 	if left.GetId() == ast.ErrId {
 		return left
 	}
@@ -530,14 +915,14 @@ func multiply(parser *tokenParser) ast.Node {
 
 		operator := parser.peek()
 
-		if operator.Id != scanner.Star && operator.Id != scanner.Slash {
+		if operator.Id != scanner.Star && operator.Id != scanner.Slash && operator.Id != scanner.Percent {
 			break
 		}
 
 		// consume operator
 		parser.advance()
 
-		right := unary(parser)
+		right := cast(parser)
 		if right.GetId() == ast.ErrId {
 			return right
 		}
@@ -548,11 +933,32 @@ func multiply(parser *tokenParser) ast.Node {
 	return left
 }
 
+// cast parses conversions like x as u8, which bind tighter than binary and looser than unary operators
+func cast(parser *tokenParser) ast.Node {
+	expr := unary(parser)
+	if expr.GetId() == ast.ErrId {
+		return expr
+	}
+
+	for parser.peek().Id == scanner.As {
+		keyword := parser.advance()
+
+		target, errNode := typeName(parser)
+		if errNode != nil {
+			return errNode
+		}
+
+		expr = &ast.CastExpr{Token: keyword, Expression: expr, TargetType: target}
+	}
+
+	return expr
+}
+
 func unary(parser *tokenParser) ast.Node {
 	current := parser.peek()
 
 	switch current.Id {
-	case scanner.Plus, scanner.Minus, scanner.Bang:
+	case scanner.Plus, scanner.Minus, scanner.Bang, scanner.Tilde:
 		_ = parser.advance()
 
 		// No recursive parsing for unary. We don't want something like +-+--10 or !!!!!boolVal
@@ -572,6 +978,38 @@ func call(parser *tokenParser) ast.Node {
 	expr := primary(parser)
 
 	for {
+		if parser.peek().Id == scanner.Dot {
+			// Consume .
+			_ = parser.advance()
+
+			name := parser.advance()
+			if name.Id != scanner.Identifier {
+				return err(name, "Expected field name", "")
+			}
+
+			expr = &ast.GetExpr{Expression: expr, Name: name}
+			continue
+		}
+
+		if parser.peek().Id == scanner.OpenBracket {
+			openBracket := parser.advance()
+
+			prev := parser.noStructLiteral
+			parser.noStructLiteral = false
+			index := expression(parser)
+			parser.noStructLiteral = prev
+			if index.GetId() == ast.ErrId {
+				return index
+			}
+
+			if parser.advance().Id != scanner.CloseBracket {
+				return err(openBracket, "Unclosed index expression", "Add missing ] to close index")
+			}
+
+			expr = &ast.IndexExpr{Token: openBracket, Expression: expr, Index: index}
+			continue
+		}
+
 		if parser.peek().Id != scanner.OpenParen {
 			return expr
 		}
@@ -606,7 +1044,10 @@ func primary(parser *tokenParser) ast.Node {
 
 	switch current.Id {
 	case scanner.OpenParen:
+		prev := parser.noStructLiteral
+		parser.noStructLiteral = false
 		node := expression(parser)
+		parser.noStructLiteral = prev
 
 		if parser.advance().Id != scanner.CloseParen {
 			return err(current, "Unclosed grouping expression", "Add missing ) to close group")
@@ -614,23 +1055,215 @@ func primary(parser *tokenParser) ast.Node {
 
 		return node
 
+	case scanner.OpenBracket:
+		return arrayLiteral(parser, current)
+
 	case scanner.Identifier:
+		if parser.peek().Id == scanner.OpenBrace && !parser.noStructLiteral {
+			return structLiteral(parser, current)
+		}
 		return &ast.IdentifierLitExpr{Token: current, Name: current.Lexeme}
 
 	case scanner.Integer:
-		return &ast.IntegerLitExpr{Token: current, Value: current.Lexeme}
+		return &ast.IntegerLitExpr{Token: current, Value: current.Value}
 
 	case scanner.Float:
-		return &ast.FloatingLitExpr{Token: current, Value: current.Lexeme}
+		return &ast.FloatingLitExpr{Token: current, Value: current.Value}
 
 	case scanner.True, scanner.False:
 		return &ast.BooleanLitExpr{Token: current, Value: current.Lexeme}
 
+	case scanner.String:
+		return &ast.StringLitExpr{Token: current, Value: current.Value}
+
+	case scanner.Match:
+		return match(parser, current)
 	}
 
 	return err(current, "Unexpected token", "")
 }
 
+// match parses match value { 1 => a, 2 | 3 => b, _ => { ... } }. Arms are expressions or blocks, commas are optional
+// after blocks.
+func match(parser *tokenParser, keyword scanner.Token) ast.Node {
+	value := conditionExpression(parser)
+	if value.GetId() == ast.ErrId {
+		return value
+	}
+
+	if parser.advance().Id != scanner.OpenBrace {
+		return err(parser.peekPrevious(), "Expected { after match value", "Add the arms like: match value { 1 => a, _ => b }")
+	}
+
+	prev := parser.noStructLiteral
+	parser.noStructLiteral = false
+	defer func() {
+		parser.noStructLiteral = prev
+	}()
+
+	patterns := make([][]ast.Node, 0)
+	arms := make([]ast.Node, 0)
+	for {
+		if parser.isDone() {
+			return err(keyword, "Unclosed match", "Add missing } to close match")
+		}
+		if parser.peek().Id == scanner.CloseBrace {
+			_ = parser.advance()
+			break
+		}
+
+		alternatives, errNode := matchPatterns(parser)
+		if errNode != nil {
+			return errNode
+		}
+
+		if parser.advance().Id != scanner.FatArrow {
+			return err(parser.peekPrevious(), "Expected => after pattern", "Separate pattern and arm like: 1 => value")
+		}
+
+		var arm ast.Node
+		if parser.peek().Id == scanner.OpenBrace {
+			arm = closure(parser)
+		} else {
+			arm = expression(parser)
+		}
+		if arm.GetId() == ast.ErrId {
+			return arm
+		}
+
+		patterns = append(patterns, alternatives)
+		arms = append(arms, arm)
+
+		if parser.peek().Id == scanner.Comma {
+			_ = parser.advance()
+			continue
+		}
+		if parser.peek().Id != scanner.CloseBrace && arm.GetId() != ast.ClosureId {
+			return err(parser.peek(), "Expected comma after match arm", "Separate arms like: 1 => a, 2 => b")
+		}
+	}
+
+	return &ast.MatchExpr{Token: keyword, Value: value, Patterns: patterns, Arms: arms}
+}
+
+// matchPatterns parses the literals of an arm separated by |. The wildcard _ has no patterns.
+func matchPatterns(parser *tokenParser) ([]ast.Node, ast.Node) {
+	if current := parser.peek(); current.Id == scanner.Identifier && current.Lexeme == "_" {
+		_ = parser.advance()
+		return nil, nil
+	}
+
+	alternatives := make([]ast.Node, 0)
+	for {
+		pattern := unary(parser)
+		if pattern.GetId() == ast.ErrId {
+			return nil, pattern
+		}
+
+		switch p := pattern.(type) {
+		case *ast.IntegerLitExpr, *ast.BooleanLitExpr, *ast.StringLitExpr:
+			break
+		case *ast.UnaryExpr:
+			if _, isInteger := p.Expression.(*ast.IntegerLitExpr); !isInteger || p.Operator.Id != scanner.Minus {
+				return nil, err(p.GetToken(), "Expected literal pattern", "Patterns are integer, bool or string literals or _")
+			}
+		default:
+			return nil, err(pattern.GetToken(), "Expected literal pattern", "Patterns are integer, bool or string literals or _")
+		}
+		alternatives = append(alternatives, pattern)
+
+		if parser.peek().Id != scanner.Pipe {
+			return alternatives, nil
+		}
+		_ = parser.advance()
+	}
+}
+
+func arrayLiteral(parser *tokenParser, openBracket scanner.Token) ast.Node {
+	prev := parser.noStructLiteral
+	parser.noStructLiteral = false
+	defer func() {
+		parser.noStructLiteral = prev
+	}()
+
+	values := make([]ast.Node, 0)
+
+	for {
+		if parser.peek().Id == scanner.CloseBracket {
+			break
+		}
+
+		value := expression(parser)
+		if value.GetId() == ast.ErrId {
+			return value
+		}
+		values = append(values, value)
+
+		if parser.peek().Id == scanner.CloseBracket {
+			break
+		}
+
+		if parser.advance().Id != scanner.Comma {
+			return err(parser.peekPrevious(), "Expected comma as element separator", "")
+		}
+	}
+
+	// Consume ]
+	_ = parser.advance()
+
+	return &ast.ArrayLitExpr{Token: openBracket, Values: values}
+}
+
+func structLiteral(parser *tokenParser, identifier scanner.Token) ast.Node {
+	// Consume {
+	_ = parser.advance()
+
+	prev := parser.noStructLiteral
+	parser.noStructLiteral = false
+	defer func() {
+		parser.noStructLiteral = prev
+	}()
+
+	fields := make([]scanner.Token, 0)
+	values := make([]ast.Node, 0)
+
+	for {
+		if parser.peek().Id == scanner.CloseBrace {
+			break
+		}
+
+		field := parser.advance()
+		if field.Id != scanner.Identifier {
+			return err(field, "Expected field name in struct literal", "")
+		}
+
+		if parser.advance().Id != scanner.Colon {
+			return err(parser.peekPrevious(), "Expected colon after field name", "Initialize fields like x: 1")
+		}
+
+		value := expression(parser)
+		if value.GetId() == ast.ErrId {
+			return value
+		}
+
+		fields = append(fields, field)
+		values = append(values, value)
+
+		if parser.peek().Id == scanner.CloseBrace {
+			break
+		}
+
+		if parser.advance().Id != scanner.Comma {
+			return err(parser.peekPrevious(), "Expected comma as field separator", "")
+		}
+	}
+
+	// Consume }
+	_ = parser.advance()
+
+	return &ast.StructLitExpr{Token: identifier, Identifier: identifier.Lexeme, Fields: fields, Values: values}
+}
+
 func err(token scanner.Token, message string, hint string) ast.Node {
 	return &ast.ErrNode{
 		Token:   token,
@@ -0,0 +1,196 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errInterrupt is returned for Ctrl-C, which discards the line
+var errInterrupt = errors.New("interrupt")
+
+type lineReader interface {
+	readLine(prompt string) (string, error)
+	interactive() bool
+}
+
+func newLineReader(in *os.File, output io.Writer, history *history) lineReader {
+	fd := int(in.Fd())
+	if isTerminal(fd) {
+		return &editor{fd: fd, reader: bufio.NewReader(in), output: output, history: history}
+	}
+	return &plainReader{reader: bufio.NewReader(in)}
+}
+
+// plainReader reads lines from pipes and files, which are not echoed so no prompt is printed either
+type plainReader struct {
+	reader *bufio.Reader
+}
+
+func (p *plainReader) readLine(string) (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err != nil && (len(line) == 0 || !errors.Is(err, io.EOF)) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (p *plainReader) interactive() bool {
+	return false
+}
+
+// editor reads lines from a terminal in raw mode, supporting cursor movement and the history
+type editor struct {
+	fd      int
+	reader  *bufio.Reader
+	output  io.Writer
+	history *history
+	// State of the line being read
+	line   []rune
+	cursor int
+	// Index of the recalled history entry, the edited line is kept below the entries
+	recalled int
+	edited   []rune
+}
+
+// Control characters of the keys
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyBackspace = 8
+	keyCtrlK     = 11
+	keyEnter     = 13
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+func (e *editor) interactive() bool {
+	return true
+}
+
+func (e *editor) readLine(prompt string) (string, error) {
+	state, err := enableRawMode(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer state.restore(e.fd)
+
+	e.line, e.cursor = nil, 0
+	e.recalled, e.edited = len(e.history.entries), nil
+
+	e.refresh(prompt)
+	for {
+		key, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case keyEnter, '\n':
+			_, _ = fmt.Fprint(e.output, "\r\n")
+			return string(e.line), nil
+		case keyCtrlC:
+			_, _ = fmt.Fprint(e.output, "^C\r\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				_, _ = fmt.Fprint(e.output, "\r\n")
+				return "", io.EOF
+			}
+			e.delete(e.cursor)
+		case keyBackspace, keyDelete:
+			if e.cursor > 0 {
+				e.cursor--
+				e.delete(e.cursor)
+			}
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlK:
+			e.line = e.line[:e.cursor:e.cursor]
+		case keyCtrlU:
+			e.line = e.line[e.cursor:]
+			e.cursor = 0
+		case keyEscape:
+			e.escape()
+		default:
+			if key >= ' ' {
+				e.line = append(e.line[:e.cursor:e.cursor], append([]rune{key}, e.line[e.cursor:]...)...)
+				e.cursor++
+			}
+		}
+		e.refresh(prompt)
+	}
+}
+
+// refresh redraws the line and moves the cursor to its column
+func (e *editor) refresh(prompt string) {
+	_, _ = fmt.Fprintf(e.output, "\r%s%s\x1b[K\r", prompt, string(e.line))
+	if column := len([]rune(prompt)) + e.cursor; column > 0 {
+		_, _ = fmt.Fprintf(e.output, "\x1b[%dC", column)
+	}
+}
+
+func (e *editor) delete(index int) {
+	if index < len(e.line) {
+		e.line = append(e.line[:index:index], e.line[index+1:]...)
+	}
+}
+
+// recall replaces the line by the history entry at index
+func (e *editor) recall(index int) {
+	entries := e.history.entries
+	if index < 0 || index > len(entries) {
+		return
+	}
+	if e.recalled == len(entries) {
+		e.edited = e.line
+	}
+
+	e.recalled = index
+	if index == len(entries) {
+		e.line = e.edited
+	} else {
+		e.line = []rune(entries[index])
+	}
+	e.cursor = len(e.line)
+}
+
+// escape handles the sequences of arrow, home, end and delete keys
+func (e *editor) escape() {
+	if next, _ := e.reader.ReadByte(); next != '[' && next != 'O' {
+		return
+	}
+	code, _ := e.reader.ReadByte()
+
+	switch code {
+	case 'A':
+		e.recall(e.recalled - 1)
+	case 'B':
+		e.recall(e.recalled + 1)
+	case 'C':
+		if e.cursor < len(e.line) {
+			e.cursor++
+		}
+	case 'D':
+		if e.cursor > 0 {
+			e.cursor--
+		}
+	case 'H':
+		e.cursor = 0
+	case 'F':
+		e.cursor = len(e.line)
+	case '3':
+		// Delete is sent as ESC [ 3 ~
+		if tilde, _ := e.reader.ReadByte(); tilde == '~' {
+			e.delete(e.cursor)
+		}
+	}
+}
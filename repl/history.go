@@ -0,0 +1,77 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory limits the entries that are loaded from the history file
+const maxHistory = 1000
+
+// history keeps previous inputs, every line is an entry so multi-line inputs can be recalled line by line
+type history struct {
+	path    string
+	entries []string
+}
+
+// DefaultHistoryPath is ~/.breeze_history, or empty if there is no home directory
+func DefaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".breeze_history")
+}
+
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if len(path) == 0 {
+		return h
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// Not created yet
+		return h
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h
+}
+
+// add appends the lines of input to the history and its file, repeated lines are only kept once
+func (h *history) add(input string) {
+	var added []string
+	for _, line := range strings.Split(input, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		if len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+			continue
+		}
+		h.entries = append(h.entries, line)
+		added = append(added, line)
+	}
+
+	if len(h.path) == 0 || len(added) == 0 {
+		return
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		// The history is still kept for this session
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	_, _ = file.WriteString(strings.Join(added, "\n") + "\n")
+}
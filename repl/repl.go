@@ -0,0 +1,308 @@
+package repl
+
+import (
+	"breeze/analyzer"
+	"breeze/ast"
+	"breeze/common"
+	"breeze/out"
+	"breeze/parser"
+	"breeze/scanner"
+	"breeze/slow"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Language is in development. Many features may change.
+// Read-eval-print loop on top of the tree-walking interpreter. Every input is analyzed and executed in a new scope
+// on top of the previous ones, so declarations persist and can be shadowed by later inputs.
+
+const help = `Enter statements, declarations or expressions. Input continues on the next line while brackets are open.
+
+Commands:
+  :type <expr>     Print the type of an expression
+  :ast <input>     Print the syntax tree of the input
+  :tokens <input>  Print the tokens of the input
+  :history         Print the previous inputs
+  :help            Print this help
+  :quit            Exit, like Ctrl-D
+`
+
+const (
+	prompt             = "breeze> "
+	continuationPrompt = "...     "
+)
+
+type session struct {
+	file    common.SourceFile
+	context *analyzer.Context
+	runtime *slow.Runtime
+	history *history
+	output  io.Writer
+	errors  io.Writer
+}
+
+// Run reads inputs from in until it ends. Lines are edited and recalled from the history at historyPath if in is a
+// terminal, an empty historyPath keeps the history in memory only.
+func Run(in *os.File, output io.Writer, errorOutput io.Writer, historyPath string) {
+	file := common.SourceFile{Path: "repl"}
+	s := &session{
+		file:    file,
+		context: analyzer.InitContext(file),
+		runtime: slow.InitRuntime(file, output),
+		history: loadHistory(historyPath),
+		output:  output,
+		errors:  errorOutput,
+	}
+
+	reader := newLineReader(in, output, s.history)
+
+	if reader.interactive() {
+		_, _ = fmt.Fprintln(output, "Breeze REPL, enter :help for commands")
+	}
+
+	for {
+		input, err := s.read(reader)
+		if err != nil {
+			return
+		}
+		if len(strings.TrimSpace(input)) == 0 {
+			continue
+		}
+
+		s.history.add(input)
+		if !s.evaluate(input) {
+			return
+		}
+	}
+}
+
+// read returns the next complete input, lines are joined while brackets are unbalanced
+func (s *session) read(reader lineReader) (string, error) {
+	var lines []string
+	for {
+		currentPrompt := prompt
+		if len(lines) > 0 {
+			currentPrompt = continuationPrompt
+		}
+
+		line, err := reader.readLine(currentPrompt)
+		if errors.Is(err, errInterrupt) {
+			// Discard the pending input
+			lines = nil
+			continue
+		}
+		if err != nil {
+			if len(lines) > 0 && errors.Is(err, io.EOF) {
+				return strings.Join(lines, "\n"), nil
+			}
+			return "", err
+		}
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if openBrackets(s.file, input) <= 0 {
+			return input, nil
+		}
+	}
+}
+
+// openBrackets counts the braces, parentheses and brackets of input that are not closed yet.
+// Brackets in strings and comments do not count.
+func openBrackets(file common.SourceFile, input string) int {
+	tokens, diagnostics := scanner.Scan(&file, input)
+	if diagnostics.HasErrors() {
+		// Reported once the input is evaluated
+		return 0
+	}
+
+	open := 0
+	for _, token := range tokens {
+		switch token.Id {
+		case scanner.OpenBrace, scanner.OpenParen, scanner.OpenBracket:
+			open++
+		case scanner.CloseBrace, scanner.CloseParen, scanner.CloseBracket:
+			open--
+		}
+	}
+	return open
+}
+
+// evaluate runs a command or input and returns false if the session ends
+func (s *session) evaluate(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, ":") {
+		s.execute(input)
+		return true
+	}
+
+	command, argument, _ := strings.Cut(trimmed[1:], " ")
+	argument = strings.TrimSpace(argument)
+
+	switch command {
+	case "type":
+		s.printType(argument)
+	case "ast":
+		s.printTree(argument)
+	case "tokens":
+		s.printTokens(argument)
+	case "history":
+		for i, entry := range s.history.entries {
+			_, _ = fmt.Fprintf(s.output, "%4d  %s\n", i+1, entry)
+		}
+	case "help":
+		_, _ = fmt.Fprint(s.output, help)
+	case "quit", "q":
+		return false
+	default:
+		out.PrintErrorMessage(fmt.Sprintf("Unknown command :%s, enter :help for commands", command))
+	}
+	return true
+}
+
+// report prints the diagnostics of source and returns false if there are errors
+func (s *session) report(source string, diagnostics *common.DiagnosticBag) bool {
+	diagnostics.Sort()
+	diagnostics.Deduplicate()
+	out.ReportDiagnostics(s.errors, source, diagnostics)
+	return !diagnostics.HasErrors()
+}
+
+// parse returns the nodes of source, the semicolon after its last statement is optional
+func (s *session) parse(source string) ([]ast.Node, bool) {
+	tokens, diagnostics := scanner.Scan(&s.file, source)
+	if !s.report(source, diagnostics) {
+		return nil, false
+	}
+
+	nodes, diagnostics := parser.ParseTokens(s.file, tokens)
+	if !diagnostics.HasErrors() {
+		return nodes, s.report(source, diagnostics)
+	}
+
+	if terminated, ok := s.parseTerminated(source); ok {
+		return terminated, true
+	}
+
+	// Errors of the input as it was entered
+	s.report(source, diagnostics)
+	return nil, false
+}
+
+func (s *session) parseTerminated(source string) ([]ast.Node, bool) {
+	if strings.HasSuffix(strings.TrimSpace(source), ";") {
+		return nil, false
+	}
+
+	tokens, diagnostics := scanner.Scan(&s.file, source+";")
+	if diagnostics.HasErrors() {
+		return nil, false
+	}
+
+	nodes, diagnostics := parser.ParseTokens(s.file, tokens)
+	if diagnostics.HasErrors() {
+		return nil, false
+	}
+	return nodes, true
+}
+
+// execute analyzes and runs source, the value of a trailing expression is printed
+func (s *session) execute(source string) {
+	nodes, ok := s.parse(source)
+	if !ok {
+		return
+	}
+	if !s.report(source, s.context.Check(nodes)) {
+		return
+	}
+
+	s.runtime.Begin()
+
+	expression, isExpression := result(nodes)
+	if isExpression {
+		nodes = nodes[:len(nodes)-1]
+	}
+
+	err := s.runtime.Execute(nodes)
+	if err == nil && isExpression {
+		var value any
+		value, err = s.runtime.Evaluate(expression)
+		if err == nil && value != nil {
+			_, _ = fmt.Fprintln(s.output, s.runtime.Format(value))
+		}
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintln(s.errors, err.Error())
+		// Declarations of a failed input may not be initialized
+		s.runtime.Discard()
+		s.context.Discard()
+	}
+}
+
+// result returns the expression of a trailing expression statement. Assignments and match statements without a result
+// type have no result.
+func result(nodes []ast.Node) (ast.Node, bool) {
+	if len(nodes) == 0 {
+		return nil, false
+	}
+
+	statement, ok := nodes[len(nodes)-1].(*ast.ExprStmt)
+	if !ok {
+		return nil, false
+	}
+
+	switch expression := statement.Expression.(type) {
+	case *ast.AssignExpr, *ast.SetExpr, *ast.SetIndexExpr:
+		return nil, false
+	case *ast.MatchExpr:
+		if len(expression.ResultType) == 0 {
+			return nil, false
+		}
+	}
+	return statement.Expression, true
+}
+
+func (s *session) printType(source string) {
+	nodes, ok := s.parse(source)
+	if !ok {
+		return
+	}
+
+	expression, ok := result(nodes)
+	if !ok || len(nodes) != 1 {
+		out.PrintErrorMessage("Expected a single expression")
+		return
+	}
+
+	typeName, diagnostics := s.context.TypeOf(expression)
+	if !s.report(source, diagnostics) {
+		return
+	}
+	if typeName == analyzer.TypeNoReference.TypeName {
+		typeName = analyzer.TypeVoidReference.TypeName
+	}
+	_, _ = fmt.Fprintln(s.output, typeName)
+}
+
+func (s *session) printTree(source string) {
+	nodes, ok := s.parse(source)
+	if !ok {
+		return
+	}
+	for _, node := range nodes {
+		_, _ = fmt.Fprintln(s.output, node.String())
+	}
+}
+
+func (s *session) printTokens(source string) {
+	tokens, diagnostics := scanner.Scan(&s.file, source)
+	if !s.report(source, diagnostics) {
+		return
+	}
+	for _, token := range tokens {
+		_, _ = fmt.Fprintln(s.output, token.Stringify())
+	}
+}
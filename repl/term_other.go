@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+
+package repl
+
+import "errors"
+
+// Other platforms read plain lines without editing
+
+type terminalState struct{}
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func enableRawMode(fd int) (*terminalState, error) {
+	return nil, errors.New("raw mode is not supported")
+}
+
+func (s *terminalState) restore(fd int) {}
@@ -2,16 +2,28 @@ package scanner
 
 import (
 	"breeze/common"
-	"breeze/out"
-	"os"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Diagnostic codes of the scanning phase
+const (
+	codeUnexpectedToken = "BZ0101"
+	codeUnterminated    = "BZ0102"
+	codeInvalidEscape   = "BZ0103"
+	codeInvalidNumber   = "BZ0104"
 )
 
 type sourceScanner struct {
-	source []rune
-	length int
-	start  common.Position
-	cursor common.Position
-	file   *common.SourceFile
+	source      []rune
+	length      int
+	start       common.Position
+	cursor      common.Position
+	file        *common.SourceFile
+	diagnostics *common.DiagnosticBag
 }
 
 func (s *sourceScanner) isDone() bool {
@@ -60,11 +72,12 @@ func initScanner(file *common.SourceFile, source string) sourceScanner {
 	runes := []rune(source)
 	runesLen := len(runes)
 	return sourceScanner{
-		source: runes,
-		length: runesLen,
-		start:  common.InitPosition(),
-		cursor: common.InitPosition(),
-		file:   file,
+		source:      runes,
+		length:      runesLen,
+		start:       common.InitPosition(),
+		cursor:      common.InitPosition(),
+		file:        file,
+		diagnostics: common.InitDiagnosticBag(),
 	}
 }
 
@@ -79,9 +92,15 @@ func makeToken(scanner *sourceScanner, id TokenId) Token {
 	}
 }
 
-func errorToken(scanner *sourceScanner, message string) Token {
-	position := scanner.start
+func errorToken(scanner *sourceScanner, code string, message string) Token {
+	return errorTokenAt(scanner, scanner.start, code, message)
+}
+
+// errorTokenAt reports an error at position and returns an Invalid token
+func errorTokenAt(scanner *sourceScanner, position common.Position, code string, message string) Token {
 	scanner.start = scanner.cursor
+	span := common.Span{Path: scanner.file.Path, Position: position, Length: 1}
+	scanner.diagnostics.Error(code, message, span)
 	return Token{
 		Id:       Invalid,
 		Lexeme:   message,
@@ -89,25 +108,78 @@ func errorToken(scanner *sourceScanner, message string) Token {
 	}
 }
 
-func skipWhitespace(scanner *sourceScanner) {
+func newline(scanner *sourceScanner) {
+	scanner.cursor.Line++
+	scanner.cursor.Column = 0
+	scanner.advance()
+}
+
+// blockComment skips a (nested) block comment. The cursor is expected to be on the opening /*.
+func blockComment(scanner *sourceScanner) bool {
+	// Consume /*
+	scanner.advance()
+	scanner.advance()
+
+	depth := 1
+	for depth > 0 {
+		if scanner.isDone() {
+			return false
+		}
+
+		current := scanner.peek()
+		switch {
+		case current == '\n':
+			newline(scanner)
+		case current == '/' && scanner.peekNext() == '*':
+			scanner.advance()
+			scanner.advance()
+			depth++
+		case current == '*' && scanner.peekNext() == '/':
+			scanner.advance()
+			scanner.advance()
+			depth--
+		default:
+			scanner.advance()
+		}
+	}
+
+	return true
+}
+
+func skipWhitespace(scanner *sourceScanner) (Token, bool) {
 	for {
 		if scanner.isDone() {
-			return
+			return Token{}, true
 		}
 
 		switch scanner.peek() {
 		case '\n':
-			scanner.cursor.Line++
-			scanner.cursor.Column = 0
-			scanner.advance()
+			newline(scanner)
 			scanner.start = scanner.cursor
 			break
-		case ' ', '\t':
+		case ' ', '\t', '\r':
 			scanner.advance()
 			scanner.start = scanner.cursor
 			break
+		case '/':
+			switch scanner.peekNext() {
+			case '/':
+				// Line comment, the newline itself is handled above
+				for !scanner.isDone() && scanner.peek() != '\n' {
+					scanner.advance()
+				}
+				scanner.start = scanner.cursor
+			case '*':
+				if !blockComment(scanner) {
+					return errorToken(scanner, codeUnterminated, "Unterminated block comment"), false
+				}
+				scanner.start = scanner.cursor
+			default:
+				return Token{}, true
+			}
+			break
 		default:
-			return
+			return Token{}, true
 		}
 	}
 }
@@ -151,6 +223,10 @@ func identifier(scanner *sourceScanner) Token {
 		return makeToken(scanner, False)
 	case "while":
 		return makeToken(scanner, While)
+	case "for":
+		return makeToken(scanner, For)
+	case "in":
+		return makeToken(scanner, In)
 	case "fn":
 		return makeToken(scanner, Fn)
 	case "return":
@@ -159,73 +235,307 @@ func identifier(scanner *sourceScanner) Token {
 		return makeToken(scanner, Continue)
 	case "break":
 		return makeToken(scanner, Break)
+	case "struct":
+		return makeToken(scanner, Struct)
+	case "as":
+		return makeToken(scanner, As)
+	case "match":
+		return makeToken(scanner, Match)
 	}
 
 	return makeToken(scanner, Identifier)
 }
 
-func number(scanner *sourceScanner) Token {
-	hadDot := scanner.peekPrevious() == '.'
+// numberBases are the names of the bases of integer literals and their digits
+var numberBases = map[int][2]string{
+	2:  {"binary", "0 and 1"},
+	8:  {"octal", "0 to 7"},
+	10: {"decimal", "0 to 9"},
+	16: {"hexadecimal", "0 to 9 and a to f"},
+}
+
+func isDigit(r rune, base int) bool {
+	switch base {
+	case 2:
+		return r == '0' || r == '1'
+	case 8:
+		return r >= '0' && r <= '7'
+	case 16:
+		return isHexDigit(r)
+	}
+	return isNumber(r)
+}
+
+// digits consumes digits of base and the _ separators between them. It returns the count of digits and the position
+// of the first separator that is not between two digits.
+func digits(scanner *sourceScanner, base int) (int, *common.Position) {
+	count := 0
+	var misplaced *common.Position
 
 	for {
-		if scanner.isDone() {
-			break
+		current := scanner.peek()
+		if isDigit(current, base) {
+			count++
+			scanner.advance()
+			continue
+		}
+		if current != '_' {
+			return count, misplaced
 		}
 
-		current := scanner.peek()
+		if misplaced == nil && (!isDigit(scanner.peekPrevious(), base) || !isDigit(scanner.peekNext(), base)) {
+			position := scanner.cursor
+			misplaced = &position
+		}
+		scanner.advance()
+	}
+}
+
+// numberError reports a malformed number literal at position, the whole literal is expected to be consumed
+func numberError(scanner *sourceScanner, position common.Position, message string, hint string) Token {
+	token := errorTokenAt(scanner, position, codeInvalidNumber, message)
+	diagnostics := scanner.diagnostics.Diagnostics
+	diagnostics[len(diagnostics)-1].Hint(hint)
+	return token
+}
+
+// number scans integer and float literals. The first digit or a leading dot is already consumed.
+// Integers may have a 0x, 0b or 0o prefix, floats a fraction and an exponent, and digits may be separated by _.
+// The value of the token is the decimal integer, or the float without separators.
+func number(scanner *sourceScanner) Token {
+	if scanner.peekPrevious() == '.' {
+		_, _ = digits(scanner, 10)
+		lexeme := string(scanner.source[scanner.start.Index:scanner.cursor.Index])
+		return numberError(scanner, scanner.start, "Missing digits before decimal point", fmt.Sprintf("Write 0%s", lexeme))
+	}
+
+	base := 10
+	if scanner.peekPrevious() == '0' {
+		switch scanner.peek() {
+		case 'x', 'X':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
+		}
+	}
 
-		if !hadDot && current == '.' {
-			hadDot = true
+	var count int
+	var misplaced *common.Position
+	isFloat := false
+
+	if base != 10 {
+		// Consume prefix
+		scanner.advance()
+		count, misplaced = digits(scanner, base)
+	} else {
+		count, misplaced = digits(scanner, base)
+		// Leading digit
+		count++
+
+		// Two dots are a range
+		if scanner.peek() == '.' && scanner.peekNext() != '.' {
 			scanner.advance()
-			continue
+			isFloat = true
+			if !isNumber(scanner.peek()) {
+				lexeme := string(scanner.source[scanner.start.Index:scanner.cursor.Index])
+				return numberError(scanner, scanner.start, "Missing digits after decimal point", fmt.Sprintf("Write %s0", lexeme))
+			}
+			if _, fraction := digits(scanner, base); misplaced == nil {
+				misplaced = fraction
+			}
 		}
 
-		if !isNumber(current) {
-			break
+		if scanner.peek() == 'e' || scanner.peek() == 'E' {
+			scanner.advance()
+			isFloat = true
+			if !scanner.match('+') {
+				scanner.match('-')
+			}
+			exponentCount, exponent := digits(scanner, base)
+			if exponentCount == 0 {
+				return numberError(scanner, scanner.start, "Missing digits of exponent", "Write the exponent like 1e3 or 1e-3")
+			}
+			if misplaced == nil {
+				misplaced = exponent
+			}
 		}
+	}
 
+	name := numberBases[base]
+	if current := scanner.peek(); isAlpha(current) || isNumber(current) {
+		position := scanner.cursor
+		for isAlpha(scanner.peek()) || isNumber(scanner.peek()) {
+			scanner.advance()
+		}
+		return numberError(scanner, position, fmt.Sprintf("Invalid digit %q in %s literal", current, name[0]), fmt.Sprintf("Digits of %s literals are %s", name[0], name[1]))
+	}
+	if base != 10 && scanner.peek() == '.' && isNumber(scanner.peekNext()) {
 		scanner.advance()
+		_, _ = digits(scanner, 10)
+		return numberError(scanner, scanner.start, fmt.Sprintf("Fraction in %s literal", name[0]), "Only decimal literals can be floats")
+	}
+	if count == 0 {
+		lexeme := string(scanner.source[scanner.start.Index:scanner.cursor.Index])
+		return numberError(scanner, scanner.start, fmt.Sprintf("Missing digits after %s", lexeme), fmt.Sprintf("Write %s digits like %s1", name[0], lexeme))
+	}
+	if misplaced != nil {
+		return numberError(scanner, *misplaced, "Misplaced digit separator", "Separate digits with a single _ between them, like 1_000")
+	}
+
+	lexeme := string(scanner.source[scanner.start.Index:scanner.cursor.Index])
+	digitsOnly := strings.ReplaceAll(lexeme, "_", "")
+	if isFloat {
+		token := makeToken(scanner, Float)
+		token.Value = digitsOnly
+		return token
+	}
+
+	if base != 10 {
+		digitsOnly = digitsOnly[2:]
+	}
+	value, _ := new(big.Int).SetString(digitsOnly, base)
+	token := makeToken(scanner, Integer)
+	token.Value = value.String()
+	return token
+}
+
+// stringToken creates a String token whose lexeme is the source between the enclosing quotes
+func stringToken(scanner *sourceScanner, value string) Token {
+	lexeme := string(scanner.source[scanner.start.Index+1 : scanner.cursor.Index-1])
+	position := scanner.start
+	scanner.start = scanner.cursor
+	return Token{
+		Id:       String,
+		Lexeme:   lexeme,
+		Value:    value,
+		Position: position,
 	}
+}
+
+func isHexDigit(r rune) bool {
+	return isNumber(r) || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
+}
+
+// escape decodes the escape sequence after a backslash. The cursor is expected to be on the backslash.
+func escape(scanner *sourceScanner) (rune, bool) {
+	// Consume \
+	scanner.advance()
+
+	switch scanner.advance() {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case '0':
+		return 0, true
+	case '\\':
+		return '\\', true
+	case '"':
+		return '"', true
+	case '\'':
+		return '\'', true
+	case 'u':
+		if !scanner.match('{') {
+			return 0, false
+		}
+
+		digits := ""
+		for isHexDigit(scanner.peek()) {
+			digits += string(scanner.advance())
+		}
+
+		if !scanner.match('}') || len(digits) == 0 || len(digits) > 6 {
+			return 0, false
+		}
+
+		codePoint, err := strconv.ParseInt(digits, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(codePoint)) {
+			return 0, false
+		}
 
-	if hadDot {
-		return makeToken(scanner, Float)
+		return rune(codePoint), true
 	}
 
-	return makeToken(scanner, Integer)
+	return 0, false
 }
 
 func text(scanner *sourceScanner) Token {
+	value := strings.Builder{}
+
+	var invalidEscape *common.Position
+
 	for {
-		if scanner.isDone() {
-			return errorToken(scanner, "Expected closing \"")
+		current := scanner.peek()
+
+		if scanner.isDone() || current == '\n' {
+			return errorToken(scanner, codeUnterminated, "Expected closing \"")
 		}
 
-		current := scanner.peek()
-		if current == '"' && scanner.peekPrevious() != '\\' {
+		if current == '"' {
 			scanner.advance()
 			break
 		}
 
-		scanner.advance()
+		if current == '\\' {
+			position := scanner.cursor
+			decoded, ok := escape(scanner)
+			if !ok && invalidEscape == nil {
+				// Keep scanning until the closing " to not report the rest of the string
+				invalidEscape = &position
+			}
+			value.WriteRune(decoded)
+			continue
+		}
+
+		value.WriteRune(scanner.advance())
 	}
 
-	// ignore opening "
-	scanner.start.Index++
+	if invalidEscape != nil {
+		return errorTokenAt(scanner, *invalidEscape, codeInvalidEscape, "Invalid escape sequence")
+	}
 
-	// ignore closing "
-	scanner.cursor.Index--
+	return stringToken(scanner, value.String())
+}
+
+// rawText scans a string enclosed in backticks. It may span multiple lines and does not decode escape sequences.
+func rawText(scanner *sourceScanner) Token {
+	for {
+		if scanner.isDone() {
+			return errorToken(scanner, codeUnterminated, "Expected closing `")
+		}
 
-	token := makeToken(scanner, String)
+		current := scanner.peek()
 
-	// revert our ignoring magic
-	scanner.cursor.Index++
-	scanner.start = scanner.cursor
+		if current == '`' {
+			scanner.advance()
+			break
+		}
 
-	return token
+		if current == '\n' {
+			newline(scanner)
+			continue
+		}
+
+		scanner.advance()
+	}
+
+	value := string(scanner.source[scanner.start.Index+1 : scanner.cursor.Index-1])
+	return stringToken(scanner, value)
 }
 
 func scanToken(scanner *sourceScanner) Token {
-	skipWhitespace(scanner)
+	if token, ok := skipWhitespace(scanner); !ok {
+		return token
+	}
+
+	if scanner.isDone() {
+		// Only whitespace left until the end of the source
+		return makeToken(scanner, EOF)
+	}
 
 	current := scanner.advance()
 
@@ -235,18 +545,24 @@ func scanToken(scanner *sourceScanner) Token {
 	}
 
 	// Number
-	if isNumber(current) || current == '.' {
+	if isNumber(current) || current == '.' && isNumber(scanner.peek()) {
 		return number(scanner)
 	}
 
 	switch current {
 	case '"':
 		return text(scanner)
+	case '`':
+		return rawText(scanner)
 	case '=':
 		if scanner.peek() == '=' {
 			scanner.advance()
 			return makeToken(scanner, EqualsEquals)
 		}
+		if scanner.peek() == '>' {
+			scanner.advance()
+			return makeToken(scanner, FatArrow)
+		}
 		return makeToken(scanner, Equals)
 	case '+':
 		if scanner.peek() == '=' {
@@ -259,6 +575,10 @@ func scanToken(scanner *sourceScanner) Token {
 			scanner.advance()
 			return makeToken(scanner, MinusEquals)
 		}
+		if scanner.peek() == '>' {
+			scanner.advance()
+			return makeToken(scanner, Arrow)
+		}
 		return makeToken(scanner, Minus)
 	case '*':
 		if scanner.peek() == '=' {
@@ -272,17 +592,47 @@ func scanToken(scanner *sourceScanner) Token {
 			return makeToken(scanner, SlashEquals)
 		}
 		return makeToken(scanner, Slash)
+	case '%':
+		if scanner.peek() == '=' {
+			scanner.advance()
+			return makeToken(scanner, PercentEquals)
+		}
+		return makeToken(scanner, Percent)
+	case '^':
+		if scanner.peek() == '=' {
+			scanner.advance()
+			return makeToken(scanner, CaretEquals)
+		}
+		return makeToken(scanner, Caret)
+	case '~':
+		return makeToken(scanner, Tilde)
 	case '<':
 		if scanner.peek() == '=' {
 			scanner.advance()
 			return makeToken(scanner, LowerEquals)
 		}
+		if scanner.peek() == '<' {
+			scanner.advance()
+			if scanner.peek() == '=' {
+				scanner.advance()
+				return makeToken(scanner, LowerLowerEquals)
+			}
+			return makeToken(scanner, LowerLower)
+		}
 		return makeToken(scanner, Lower)
 	case '>':
 		if scanner.peek() == '=' {
 			scanner.advance()
 			return makeToken(scanner, GreaterEquals)
 		}
+		if scanner.peek() == '>' {
+			scanner.advance()
+			if scanner.peek() == '=' {
+				scanner.advance()
+				return makeToken(scanner, GreaterGreaterEquals)
+			}
+			return makeToken(scanner, GreaterGreater)
+		}
 		return makeToken(scanner, Greater)
 	case '!':
 		if scanner.peek() == '=' {
@@ -295,12 +645,20 @@ func scanToken(scanner *sourceScanner) Token {
 			scanner.advance()
 			return makeToken(scanner, PipePipe)
 		}
+		if scanner.peek() == '=' {
+			scanner.advance()
+			return makeToken(scanner, PipeEquals)
+		}
 		return makeToken(scanner, Pipe)
 	case '&':
 		if scanner.peek() == '&' {
 			scanner.advance()
 			return makeToken(scanner, AndAnd)
 		}
+		if scanner.peek() == '=' {
+			scanner.advance()
+			return makeToken(scanner, AndEquals)
+		}
 		return makeToken(scanner, And)
 	case ';':
 		return makeToken(scanner, Semicolon)
@@ -320,15 +678,24 @@ func scanToken(scanner *sourceScanner) Token {
 		return makeToken(scanner, CloseBracket)
 	case ',':
 		return makeToken(scanner, Comma)
+	case '.':
+		if scanner.peek() == '.' {
+			scanner.advance()
+			return makeToken(scanner, DotDot)
+		}
+		return makeToken(scanner, Dot)
+	}
+
+	if current == '💨' {
+		return errorToken(scanner, codeUnexpectedToken, "This breeze is unfortunately an unexpected token")
 	}
 
-	return errorToken(scanner, "Unexpected token")
+	return errorToken(scanner, codeUnexpectedToken, "Unexpected token")
 }
 
-func Scan(file *common.SourceFile, source string) ([]Token, bool) {
+func Scan(file *common.SourceFile, source string) ([]Token, *common.DiagnosticBag) {
 	scanner := initScanner(file, source)
 	var tokens []Token
-	var hadError = false
 
 	for {
 		if scanner.isDone() {
@@ -336,18 +703,12 @@ func Scan(file *common.SourceFile, source string) ([]Token, bool) {
 		}
 
 		token := scanToken(&scanner)
-		if token.Id == Invalid {
-			hadError = true
-
-			if scanner.peekPrevious() != '💨' {
-				out.PrintErrorMessage(token.Lexeme)
-			} else {
-				out.PrintErrorMessage("This breeze is unfortunately an unexpected token")
-			}
-
-			out.PrintErrorSource(file.Path, token.Position)
-			out.PrintMarkedLine(os.Stderr, source, 1, token.Position, out.ColorRed, '^')
+		if token.Id == EOF {
+			break
+		}
 
+		if token.Id == Invalid {
+			// Already reported
 			continue
 		}
 		tokens = append(tokens, token)
@@ -355,5 +716,5 @@ func Scan(file *common.SourceFile, source string) ([]Token, bool) {
 
 	tokens = append(tokens, makeToken(&scanner, EOF))
 
-	return tokens, hadError
+	return tokens, scanner.diagnostics
 }
@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"breeze/common"
+	"testing"
+)
+
+func TestScanDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		code   string
+		line   int
+		column int
+	}{
+		{"unterminated block comment", "let x = 1;\n/* a\n   b", codeUnterminated, 2, 1},
+		{"unterminated nested block comment", "/* /* */", codeUnterminated, 1, 1},
+		{"token after nested block comment", "/* /* */\n*/ let y = @;", codeUnexpectedToken, 2, 12},
+		{"token after line comment", "// @\n @", codeUnexpectedToken, 2, 2},
+		{"invalid escape", `let s = "ab\q";`, codeInvalidEscape, 1, 12},
+		{"invalid unicode escape", `let s = "\u{110000}";`, codeInvalidEscape, 1, 10},
+		{"unterminated string", `let s = "abc`, codeUnterminated, 1, 9},
+		{"unterminated raw string", "let s = `ab\ncd", codeUnterminated, 1, 9},
+		{"token after raw string", "`a\nb` @", codeUnexpectedToken, 2, 4},
+		{"missing hexadecimal digits", "let x = 0x;", codeInvalidNumber, 1, 9},
+		{"missing fraction", "let x = 1.;", codeInvalidNumber, 1, 9},
+		{"invalid binary digit", "let x = 0b102;", codeInvalidNumber, 1, 13},
+		{"missing exponent", "let x = 1e;", codeInvalidNumber, 1, 9},
+		{"misplaced separator", "let x = 1__0;", codeInvalidNumber, 1, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, diagnostics := Scan(&common.SourceFile{Path: "test.bz"}, test.source)
+			if len(diagnostics.Diagnostics) != 1 {
+				t.Fatalf("expected 1 diagnostic, got %d", len(diagnostics.Diagnostics))
+			}
+
+			diagnostic := diagnostics.Diagnostics[0]
+			position := diagnostic.Span.Position
+			if diagnostic.Code != test.code || position.Line != test.line || position.Column != test.column {
+				t.Errorf("expected %s at %d:%d, got %s at %d:%d (%s)", test.code, test.line, test.column,
+					diagnostic.Code, position.Line, position.Column, diagnostic.Message)
+			}
+		})
+	}
+}
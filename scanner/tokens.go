@@ -21,14 +21,30 @@ const (
 	Bang
 	And
 	Pipe
+	Percent
+	Caret
+	Tilde
 
 	// 2-char operators
 	PlusEquals
 	MinusEquals
 	StarEquals
 	SlashEquals
+	PercentEquals
+	AndEquals
+	PipeEquals
+	CaretEquals
 	AndAnd
 	PipePipe
+	LowerLower
+	GreaterGreater
+	Arrow
+	FatArrow
+	DotDot
+
+	// 3-char operators
+	LowerLowerEquals
+	GreaterGreaterEquals
 
 	// Comparative
 	Lower
@@ -44,10 +60,15 @@ const (
 	If
 	Else
 	While
+	For
+	In
 	Fn
 	Return
 	Continue
 	Break
+	Struct
+	As
+	Match
 
 	// Literals
 	Identifier
@@ -67,11 +88,32 @@ const (
 	Semicolon
 	Colon
 	Comma
+	Dot
 )
 
+var compoundOperators = map[TokenId]TokenId{
+	PlusEquals:           Plus,
+	MinusEquals:          Minus,
+	StarEquals:           Star,
+	SlashEquals:          Slash,
+	PercentEquals:        Percent,
+	AndEquals:            And,
+	PipeEquals:           Pipe,
+	CaretEquals:          Caret,
+	LowerLowerEquals:     LowerLower,
+	GreaterGreaterEquals: GreaterGreater,
+}
+
+// CompoundOperator returns the binary operator applied by a compound assignment operator like +=
+func CompoundOperator(id TokenId) (TokenId, bool) {
+	operator, ok := compoundOperators[id]
+	return operator, ok
+}
+
 type Token struct {
 	Id       TokenId
 	Lexeme   string
+	Value    string // Decoded value of literals
 	Position common.Position
 }
 
@@ -79,10 +121,24 @@ func (t *Token) Stringify() string {
 	return fmt.Sprintf("#%2d: %s", t.Id, t.Lexeme)
 }
 
+func (t Token) String() string {
+	return t.Lexeme
+}
+
 func (t *Token) LexemeLength() int {
 	runes := []rune(t.Lexeme)
 	if t.Id == String {
+		// Only mark the first line of multiline strings
+		for i, r := range runes {
+			if r == '\n' {
+				return i + 1
+			}
+		}
 		return len(runes) + 2
 	}
 	return len(runes)
 }
+
+func (t *Token) Span(file common.SourceFile) common.Span {
+	return common.Span{Path: file.Path, Position: t.Position, Length: t.LexemeLength()}
+}
@@ -0,0 +1,18 @@
+//go:build !plan9
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// terminatingSignal returns the name and number of the signal that terminated a process
+func terminatingSignal(state *os.ProcessState) (string, int, bool) {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return "", 0, false
+	}
+	return fmt.Sprintf("%s (%d)", status.Signal(), int(status.Signal())), int(status.Signal()), true
+}
@@ -0,0 +1,9 @@
+package main
+
+import "os"
+
+// Processes on Plan 9 end with notes instead of signals
+
+func terminatingSignal(state *os.ProcessState) (string, int, bool) {
+	return "", 0, false
+}
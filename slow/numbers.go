@@ -0,0 +1,228 @@
+package slow
+
+import (
+	"breeze/ast"
+	"breeze/scanner"
+	"fmt"
+	"unsafe"
+)
+
+// Numbers are the Go types of the same size: int8 to int64, uint8 to uint64, uint for usize, float32 and float64.
+// int and float are int64 and float64.
+
+type integer interface {
+	~int8 | ~int16 | ~int32 | ~int64 | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uint
+}
+
+type number interface {
+	integer | ~float32 | ~float64
+}
+
+// convert returns the number value as the numeric type typeName, like a cast in C. Floats out of the range of an
+// integer type saturate.
+func convert(value any, typeName string) any {
+	if target, _ := ast.NumberType(typeName); target.Kind != ast.Floating {
+		switch v := value.(type) {
+		case float32:
+			return saturate(float64(v), target, typeName)
+		case float64:
+			return saturate(v, target, typeName)
+		}
+	}
+
+	switch v := value.(type) {
+	case int8:
+		return convertNumber(v, typeName)
+	case int16:
+		return convertNumber(v, typeName)
+	case int32:
+		return convertNumber(v, typeName)
+	case int64:
+		return convertNumber(v, typeName)
+	case uint8:
+		return convertNumber(v, typeName)
+	case uint16:
+		return convertNumber(v, typeName)
+	case uint32:
+		return convertNumber(v, typeName)
+	case uint64:
+		return convertNumber(v, typeName)
+	case uint:
+		return convertNumber(v, typeName)
+	case float32:
+		return convertNumber(v, typeName)
+	case float64:
+		return convertNumber(v, typeName)
+	}
+	return nil
+}
+
+func saturate(value float64, target ast.Number, typeName string) any {
+	bits := target.Saturate(value)
+	if target.Kind == ast.Signed {
+		return convertNumber(int64(bits), typeName)
+	}
+	return convertNumber(bits, typeName)
+}
+
+func convertNumber[T number](value T, typeName string) any {
+	switch typeName {
+	case "int", "i64":
+		return int64(value)
+	case "i8":
+		return int8(value)
+	case "i16":
+		return int16(value)
+	case "i32":
+		return int32(value)
+	case "u8":
+		return uint8(value)
+	case "u16":
+		return uint16(value)
+	case "u32":
+		return uint32(value)
+	case "u64":
+		return uint64(value)
+	case "usize":
+		return uint(value)
+	case "f32":
+		return float32(value)
+	case "float", "f64":
+		return float64(value)
+	}
+	return nil
+}
+
+// binaryNumber applies operator to numbers of the same type, it returns false for operators that compare any values
+func (r *Runtime) binaryNumber(at ast.Node, operator scanner.TokenId, left any, right any) (any, bool) {
+	switch l := left.(type) {
+	case int8:
+		return integerOperation(r, at, operator, l, right.(int8))
+	case int16:
+		return integerOperation(r, at, operator, l, right.(int16))
+	case int32:
+		return integerOperation(r, at, operator, l, right.(int32))
+	case int64:
+		return integerOperation(r, at, operator, l, right.(int64))
+	case uint8:
+		return integerOperation(r, at, operator, l, right.(uint8))
+	case uint16:
+		return integerOperation(r, at, operator, l, right.(uint16))
+	case uint32:
+		return integerOperation(r, at, operator, l, right.(uint32))
+	case uint64:
+		return integerOperation(r, at, operator, l, right.(uint64))
+	case uint:
+		return integerOperation(r, at, operator, l, right.(uint))
+	case float32:
+		return operation(operator, l, right.(float32))
+	case float64:
+		return operation(operator, l, right.(float64))
+	}
+	return nil, false
+}
+
+func integerOperation[T integer](r *Runtime, at ast.Node, operator scanner.TokenId, left T, right T) (any, bool) {
+	switch operator {
+	case scanner.Slash, scanner.Percent:
+		if right == 0 {
+			r.fail(at, "division by zero")
+		}
+		if operator == scanner.Percent {
+			return left % right, true
+		}
+	case scanner.And:
+		return left & right, true
+	case scanner.Pipe:
+		return left | right, true
+	case scanner.Caret:
+		return left ^ right, true
+	case scanner.LowerLower, scanner.GreaterGreater:
+		// Shifting by the width or more is undefined in C
+		bits := int(unsafe.Sizeof(left)) * 8
+		if right < 0 || uint64(right) >= uint64(bits) {
+			r.fail(at, fmt.Sprintf("shift count out of range for %d bits", bits))
+		}
+		if operator == scanner.LowerLower {
+			return left << right, true
+		}
+		return left >> right, true
+	}
+	return operation(operator, left, right)
+}
+
+func operation[T number](operator scanner.TokenId, left T, right T) (any, bool) {
+	switch operator {
+	case scanner.Plus:
+		return left + right, true
+	case scanner.Minus:
+		return left - right, true
+	case scanner.Star:
+		return left * right, true
+	case scanner.Slash:
+		return left / right, true
+	case scanner.Lower:
+		return left < right, true
+	case scanner.Greater:
+		return left > right, true
+	case scanner.LowerEquals:
+		return left <= right, true
+	case scanner.GreaterEquals:
+		return left >= right, true
+	}
+	return nil, false
+}
+
+// complement returns the integer value with all bits inverted
+func complement(value any) any {
+	switch v := value.(type) {
+	case int8:
+		return ^v
+	case int16:
+		return ^v
+	case int32:
+		return ^v
+	case int64:
+		return ^v
+	case uint8:
+		return ^v
+	case uint16:
+		return ^v
+	case uint32:
+		return ^v
+	case uint64:
+		return ^v
+	case uint:
+		return ^v
+	}
+	return nil
+}
+
+// negate returns the negated number value, unsigned values wrap around
+func negate(value any) any {
+	switch v := value.(type) {
+	case int8:
+		return -v
+	case int16:
+		return -v
+	case int32:
+		return -v
+	case int64:
+		return -v
+	case uint8:
+		return -v
+	case uint16:
+		return -v
+	case uint32:
+		return -v
+	case uint64:
+		return -v
+	case uint:
+		return -v
+	case float32:
+		return -v
+	case float64:
+		return -v
+	}
+	return nil
+}
@@ -2,19 +2,31 @@ package slow
 
 import (
 	"breeze/ast"
+	"breeze/common"
 	"breeze/scanner"
 	"fmt"
+	"io"
+	"path/filepath"
 	"strconv"
 )
 
 // Language is in development. Many features may change.
-// Would be too much work to implement a VM right now. Temporary GlobalRuntime written in Go.
+// Tree-walking interpreter written in Go, runs programs without a C compiler.
 
 type Runtime struct {
 	ast.Visitor
+	File    common.SourceFile
+	Output  io.Writer
 	Current *Environment
+	Globals *Environment
+	structs map[string]*ast.StructDecl
+	// Calls in progress, limited to maxDepth
+	depth int
 }
 
+// maxDepth limits the depth of calls like in the virtual machine, deeper recursion is reported as stack overflow
+const maxDepth = 1 << 16
+
 type Environment struct {
 	Parent    *Environment
 	Variables map[string]any
@@ -51,24 +63,191 @@ func initEnv(parent *Environment) *Environment {
 	return &Environment{Parent: parent, Variables: make(map[string]any)}
 }
 
-var GlobalRuntime = Runtime{Current: initEnv(nil)}
+func InitRuntime(file common.SourceFile, output io.Writer) *Runtime {
+	globals := initEnv(nil)
+	for name, builtin := range builtins {
+		globals.Variables[name] = builtin
+	}
+	return &Runtime{File: file, Output: output, Current: globals, Globals: globals, structs: make(map[string]*ast.StructDecl)}
+}
+
+// Run executes the top level statements of nodes and returns the result of main as exit code
+func Run(file common.SourceFile, nodes []ast.Node, output io.Writer) (int, error) {
+	r := InitRuntime(file, output)
+
+	err := r.Execute(nodes)
+	if err != nil {
+		return 1, err
+	}
+
+	return r.Main()
+}
+
+// Execute runs nodes in the current environment, which is kept for following calls
+func (r *Runtime) Execute(nodes []ast.Node) (err error) {
+	defer r.recover(r.Current, &err)
+
+	// Functions can be called before their declaration
+	for _, node := range nodes {
+		if node.GetId() == ast.FunctionId {
+			_ = node.Visit(r)
+		}
+	}
+
+	for _, node := range nodes {
+		if node.GetId() != ast.FunctionId {
+			_ = node.Visit(r)
+		}
+	}
+
+	return nil
+}
+
+// Main calls main if it is declared and returns its result as exit code
+func (r *Runtime) Main() (code int, err error) {
+	defer r.recover(r.Current, &err)
+
+	fn, ok := r.Globals.Variables["main"].(*functionValue)
+	if !ok {
+		return 0, nil
+	}
+
+	result := r.call(fn, []any{})
+	if result == nil {
+		return 0, nil
+	}
+	return int(result.(int64)), nil
+}
+
+// Evaluate returns the value of expression in the current environment, nil for calls without a result
+func (r *Runtime) Evaluate(expression ast.Node) (value any, err error) {
+	defer r.recover(r.Current, &err)
+	return expression.Visit(r), nil
+}
+
+// Begin starts an environment on top of the current one, its declarations shadow the previous ones
+func (r *Runtime) Begin() {
+	r.Current = initEnv(r.Current)
+}
+
+// Discard removes the environment of the last Begin
+func (r *Runtime) Discard() {
+	if r.Current.Parent != nil {
+		r.Current = r.Current.Parent
+	}
+}
+
+func (r *Runtime) recover(environment *Environment, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	runtimeErr, ok := recovered.(*RuntimeError)
+	if !ok {
+		panic(recovered)
+	}
+
+	// Unwind to the environment the execution started in, which is outside of any call
+	r.Current = environment
+	r.depth = 0
+	*err = runtimeErr
+}
+
+// RuntimeError stops the execution of a program, like a failed bounds check
+type RuntimeError struct {
+	Location string
+	Message  string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Location + e.Message
+}
+
+// location formats the position of token like the C backend does
+func (r *Runtime) location(token scanner.Token) string {
+	position := token.Position
+	return fmt.Sprintf("[%s:%d:%d] ", filepath.Base(r.File.Path), position.Line, position.Column)
+}
+
+func (r *Runtime) fail(at ast.Node, message string) {
+	panic(&RuntimeError{Location: r.location(at.GetToken()), Message: message})
+}
 
 func (r *Runtime) VisitLetDecl(node *ast.LetDecl) any {
-	r.Current.Variables[node.Identifier] = nil
+	var value any
+	if _, isSlice := ast.SliceType(node.Type); isSlice {
+		// Slices start empty like in the C backend
+		value = []any{}
+	}
+	r.Current.Variables[node.Identifier] = value
 	return nil
 }
 
 func (r *Runtime) VisitDebugStmt(node *ast.DebugStmt) any {
 	result := node.Expression.Visit(r)
-	fmt.Println(result)
+	_, _ = fmt.Fprintf(r.Output, "%s%s\n", r.location(node.GetToken()), format(result))
 	return nil
 }
 
+func (r *Runtime) VisitFunctionDecl(node *ast.FunctionDecl) any {
+	r.Current.Variables[node.Identifier] = &functionValue{Declaration: node, Closure: r.Current}
+	return nil
+}
+
+func (r *Runtime) VisitReturnStmt(node *ast.ReturnStmt) any {
+	if node.Expression == nil {
+		return &returnSignal{}
+	}
+	return &returnSignal{Value: node.Expression.Visit(r)}
+}
+
+func (r *Runtime) VisitBreakStmt(node *ast.BreakStmt) any {
+	return breakSignal{}
+}
+
+func (r *Runtime) VisitContinueStmt(node *ast.ContinueStmt) any {
+	return continueSignal{}
+}
+
 func (r *Runtime) VisitExprStmt(node *ast.ExprStmt) any {
+	if match, ok := node.Expression.(*ast.MatchExpr); ok {
+		// Signals of block arms are passed on
+		arm := r.arm(match)
+		if arm == nil {
+			return nil
+		}
+		if arm.GetId() == ast.ClosureId {
+			return arm.Visit(r)
+		}
+		arm.Visit(r)
+		return nil
+	}
 	node.Expression.Visit(r)
 	return nil
 }
 
+func (r *Runtime) VisitMatchExpr(node *ast.MatchExpr) any {
+	// Match values are exhaustive
+	return r.arm(node).Visit(r)
+}
+
+// arm returns the first arm of node with a pattern equal to the matched value, or nil if no arm matches
+func (r *Runtime) arm(node *ast.MatchExpr) ast.Node {
+	value := node.Value.Visit(r)
+	for i, alternatives := range node.Patterns {
+		if len(alternatives) == 0 {
+			return node.Arms[i]
+		}
+		for _, pattern := range alternatives {
+			if r.binary(pattern, scanner.EqualsEquals, value, pattern.Visit(r)) == true {
+				return node.Arms[i]
+			}
+		}
+	}
+	return nil
+}
+
 func (r *Runtime) VisitWhileStmt(node *ast.WhileStmt) any {
 	for {
 		result := node.Condition.Visit(r)
@@ -77,19 +256,104 @@ func (r *Runtime) VisitWhileStmt(node *ast.WhileStmt) any {
 			break
 		}
 
-		_ = node.Statement.Visit(r)
+		switch signal := node.Statement.Visit(r).(type) {
+		case breakSignal:
+			return nil
+		case *returnSignal:
+			return signal
+		}
+	}
+
+	return nil
+}
+
+func (r *Runtime) VisitForStmt(node *ast.ForStmt) any {
+	// Variables of the initializer are only visible in the loop
+	before := r.Current
+	r.Current = initEnv(r.Current)
+	defer func() { r.Current = before }()
+
+	if node.Initializer != nil {
+		node.Initializer.Visit(r)
+	}
+
+	for node.Condition == nil || isTrue(node.Condition.Visit(r)) {
+		switch signal := node.Statement.Visit(r).(type) {
+		case breakSignal:
+			return nil
+		case *returnSignal:
+			return signal
+		}
+
+		if node.Increment != nil {
+			node.Increment.Visit(r)
+		}
 	}
 
 	return nil
 }
 
+func (r *Runtime) VisitForInStmt(node *ast.ForInStmt) any {
+	if node.End != nil {
+		return r.forRange(node)
+	}
+
+	// Arrays are iterated as copy like in the C backend, slices keep their length
+	var elements []any
+	switch collection := node.Expression.Visit(r).(type) {
+	case *arrayValue:
+		elements = copyValue(collection).(*arrayValue).Elements
+	case []any:
+		elements = collection
+	}
+
+	for _, element := range elements {
+		if signal, stop := r.iteration(node, copyValue(element)); stop {
+			return signal
+		}
+	}
+	return nil
+}
+
+func (r *Runtime) forRange(node *ast.ForInStmt) any {
+	i := node.Expression.Visit(r)
+	end := node.End.Visit(r)
+	one := convert(int64(1), node.Type)
+
+	for ; r.binary(node, scanner.Lower, i, end) == true; i = r.binary(node, scanner.Plus, i, one) {
+		if signal, stop := r.iteration(node, i); stop {
+			return signal
+		}
+	}
+	return nil
+}
+
+// iteration runs the body of the loop with the name bound to value, it reports whether the loop ends and the signal
+// to pass on
+func (r *Runtime) iteration(node *ast.ForInStmt, value any) (any, bool) {
+	before := r.Current
+	r.Current = initEnv(r.Current)
+	r.Current.Variables[node.Name.Lexeme] = value
+	signal := node.Statement.Visit(r)
+	r.Current = before
+
+	switch signal.(type) {
+	case breakSignal:
+		return nil, true
+	case *returnSignal:
+		return signal, true
+	}
+	return nil, false
+}
+
 func (r *Runtime) VisitConditionalStmt(node *ast.ConditionalStmt) any {
 	result := node.Condition.Visit(r)
 
+	// Signals of the executed branch are passed on
 	if isTrue(result) {
-		_ = node.Statement.Visit(r)
+		return node.Statement.Visit(r)
 	} else if node.ElseStatement != nil {
-		_ = node.ElseStatement.Visit(r)
+		return node.ElseStatement.Visit(r)
 	}
 
 	return nil
@@ -104,17 +368,21 @@ func (r *Runtime) VisitClosureStmt(node *ast.ClosureStmt) any {
 
 	before := r.Current
 	r.Current = initEnv(r.Current)
-	_ = block.Visit(r)
+	signal := block.Visit(r)
 	r.Current = before
 
-	return nil
+	return signal
 }
 
 func (r *Runtime) VisitBlockStmt(node *ast.BlockStmt) any {
 	nodes := node.Nodes
 
 	for _, n := range nodes {
-		_ = n.Visit(r)
+		// Statements only return signals, stop at the first one
+		signal := n.Visit(r)
+		if signal != nil {
+			return signal
+		}
 	}
 
 	return nil
@@ -123,75 +391,50 @@ func (r *Runtime) VisitBlockStmt(node *ast.BlockStmt) any {
 func (r *Runtime) VisitAssignExpr(node *ast.AssignExpr) any {
 	name := node.Name.Lexeme
 
-	switch node.Operator.Id {
-	case scanner.Equals:
-		val := node.Value.Visit(r)
-		GlobalRuntime.Current.set(name, val)
-		return val
-	}
-
-	return nil
-}
+	val := node.Value.Visit(r)
 
-func getType(v interface{}) string {
-	switch v.(type) {
-	case float32:
-		return "float32"
-	case int:
-		return "int"
-	case bool:
-		return "bool"
+	if operator, ok := scanner.CompoundOperator(node.Operator.Id); ok {
+		val = r.binary(node, operator, r.Current.get(name), val)
 	}
-	return ""
+
+	r.Current.set(name, copyValue(val))
+	return val
 }
 
 func (r *Runtime) VisitBinaryExpr(node *ast.BinaryExpr) any {
 	left := node.Left.Visit(r)
-	right := node.Right.Visit(r)
 
-	leftType := getType(left)
-
-	if leftType == "int" {
-		switch node.Operator.Id {
-		case scanner.Plus:
-			return left.(int) + right.(int)
-		case scanner.Minus:
-			return left.(int) - right.(int)
-		case scanner.Star:
-			return left.(int) * right.(int)
-		case scanner.Slash:
-			return left.(int) / right.(int)
-		case scanner.Lower:
-			return left.(int) < right.(int)
-		case scanner.Greater:
-			return left.(int) > right.(int)
-		case scanner.LowerEquals:
-			return left.(int) <= right.(int)
-		case scanner.GreaterEquals:
-			return left.(int) >= right.(int)
+	// Short circuit logical operators
+	switch node.Operator.Id {
+	case scanner.AndAnd:
+		if !isTrue(left) {
+			return false
 		}
-	} else if leftType == "float32" {
-		switch node.Operator.Id {
-		case scanner.Plus:
-			return left.(float32) + right.(float32)
-		case scanner.Minus:
-			return left.(float32) - right.(float32)
-		case scanner.Star:
-			return left.(float32) * right.(float32)
-		case scanner.Slash:
-			return left.(float32) / right.(float32)
-		case scanner.Lower:
-			return left.(float32) < right.(float32)
-		case scanner.Greater:
-			return left.(float32) > right.(float32)
-		case scanner.LowerEquals:
-			return left.(float32) <= right.(float32)
-		case scanner.GreaterEquals:
-			return left.(float32) >= right.(float32)
+		return isTrue(node.Right.Visit(r))
+	case scanner.PipePipe:
+		if isTrue(left) {
+			return true
 		}
+		return isTrue(node.Right.Visit(r))
 	}
 
-	switch node.Operator.Id {
+	right := node.Right.Visit(r)
+
+	return r.binary(node, node.Operator.Id, left, right)
+}
+
+func (r *Runtime) binary(at ast.Node, operator scanner.TokenId, left any, right any) any {
+	if result, ok := r.binaryNumber(at, operator, left, right); ok {
+		return result
+	}
+
+	if operator == scanner.Plus {
+		if l, ok := left.(string); ok {
+			return l + right.(string)
+		}
+	}
+
+	switch operator {
 	case scanner.EqualsEquals:
 		return left == right
 	case scanner.BangEquals:
@@ -206,31 +449,14 @@ func (r *Runtime) VisitUnaryExpr(node *ast.UnaryExpr) any {
 
 	switch node.Operator.Id {
 	case scanner.Plus:
-		return +value.(int)
+		return value
 	case scanner.Minus:
-		return -value.(int)
-	}
-
-	valueType := getType(value)
-
-	if valueType == "int" {
-		switch node.Operator.Id {
-		case scanner.Plus:
-			return +value.(int)
-		case scanner.Minus:
-			return -value.(int)
-		}
-	} else if valueType == "float32" {
-		switch node.Operator.Id {
-		case scanner.Plus:
-			return +value.(float32)
-		case scanner.Minus:
-			return -value.(float32)
-		}
-	} else if valueType == "bool" {
-		switch node.Operator.Id {
-		case scanner.Bang:
-			return !value.(bool)
+		return negate(value)
+	case scanner.Tilde:
+		return complement(value)
+	case scanner.Bang:
+		if b, ok := value.(bool); ok {
+			return !b
 		}
 	}
 
@@ -242,8 +468,12 @@ func (r *Runtime) VisitIdentifierLitExpr(node *ast.IdentifierLitExpr) any {
 }
 
 func (r *Runtime) VisitIntegerLitExpr(node *ast.IntegerLitExpr) any {
-	i, _ := strconv.Atoi(node.Value)
-	return i
+	// The analyzer allows 1 << 63 only if negated, which wraps to itself
+	i, _ := strconv.ParseUint(node.Value, 10, 64)
+	if len(node.Type) == 0 {
+		return int64(i)
+	}
+	return convert(i, node.Type)
 }
 
 func (r *Runtime) VisitBooleanLitExpr(node *ast.BooleanLitExpr) any {
@@ -255,6 +485,157 @@ func (r *Runtime) VisitErrNode(node *ast.ErrNode) any {
 }
 
 func (r *Runtime) VisitFloatingLitExpr(node *ast.FloatingLitExpr) any {
-	f, _ := strconv.ParseFloat(node.Value, 32)
-	return f
+	f, _ := strconv.ParseFloat(node.Value, 64)
+	if len(node.Type) == 0 {
+		return f
+	}
+	return convert(f, node.Type)
+}
+
+func (r *Runtime) VisitStringLitExpr(node *ast.StringLitExpr) any {
+	return node.Value
+}
+
+// builtins are the functions every program has access to
+var builtins = map[string]builtinValue{
+	"len": func(arguments []any) any {
+		switch collection := arguments[0].(type) {
+		case *arrayValue:
+			return int64(len(collection.Elements))
+		case []any:
+			return int64(len(collection))
+		}
+		return int64(len(arguments[0].(string)))
+	},
+	"append": func(arguments []any) any {
+		return appendValue(arguments[0].([]any), copyValue(arguments[1]))
+	},
+}
+
+func (r *Runtime) VisitCallExpr(node *ast.CallExpr) any {
+	callee := node.Expression.Visit(r)
+
+	arguments := make([]any, 0, len(node.Arguments))
+	for _, argument := range node.Arguments {
+		arguments = append(arguments, argument.Visit(r))
+	}
+
+	switch fn := callee.(type) {
+	case builtinValue:
+		return fn(arguments)
+	case *functionValue:
+		if r.depth >= maxDepth {
+			r.fail(node, "stack overflow")
+		}
+		return r.call(fn, arguments)
+	}
+
+	r.fail(node, "called value is not a function")
+	return nil
+}
+
+// call runs fn in a new frame with the arguments bound to its parameters
+func (r *Runtime) call(fn *functionValue, arguments []any) any {
+	frame := initEnv(fn.Closure)
+	for i, name := range fn.Declaration.ParamName {
+		frame.Variables[name] = copyValue(arguments[i])
+	}
+
+	before := r.Current
+	r.Current = frame
+	r.depth++
+	signal := fn.Declaration.Closure.Visit(r)
+	r.depth--
+	r.Current = before
+
+	if result, ok := signal.(*returnSignal); ok {
+		return result.Value
+	}
+	return nil
+}
+
+func (r *Runtime) VisitStructDecl(node *ast.StructDecl) any {
+	r.structs[node.Identifier] = node
+	return nil
+}
+
+func (r *Runtime) VisitStructLitExpr(node *ast.StructLitExpr) any {
+	fields := make(map[string]any, len(node.Fields))
+	for i, field := range node.Fields {
+		fields[field.Lexeme] = copyValue(node.Values[i].Visit(r))
+	}
+	return &structValue{Name: node.Identifier, Fields: fields}
+}
+
+func (r *Runtime) VisitGetExpr(node *ast.GetExpr) any {
+	s := node.Expression.Visit(r).(*structValue)
+	return s.Fields[node.Name.Lexeme]
+}
+
+func (r *Runtime) VisitSetExpr(node *ast.SetExpr) any {
+	// The analyzer guarantees a variable base, so the instance is modified in place
+	s := node.Expression.Visit(r).(*structValue)
+	name := node.Name.Lexeme
+
+	val := node.Value.Visit(r)
+
+	if operator, ok := scanner.CompoundOperator(node.Operator.Id); ok {
+		val = r.binary(node, operator, s.Fields[name], val)
+	}
+
+	s.Fields[name] = copyValue(val)
+	return val
+}
+
+func (r *Runtime) VisitArrayLitExpr(node *ast.ArrayLitExpr) any {
+	elements := make([]any, 0, len(node.Values))
+	for _, value := range node.Values {
+		elements = append(elements, copyValue(value.Visit(r)))
+	}
+
+	if _, isSlice := ast.SliceType(node.Type); isSlice {
+		return elements
+	}
+	return &arrayValue{Elements: elements}
+}
+
+// elements returns the elements of an array or slice and the checked index into them
+func (r *Runtime) elements(at ast.Node, expression ast.Node, index ast.Node) ([]any, int) {
+	var elements []any
+	switch collection := expression.Visit(r).(type) {
+	case *arrayValue:
+		elements = collection.Elements
+	case []any:
+		elements = collection
+	}
+
+	i := convert(index.Visit(r), "int").(int64)
+	if i < 0 || i >= int64(len(elements)) {
+		r.fail(at, fmt.Sprintf("index out of bounds: index %d, length %d", i, len(elements)))
+	}
+
+	return elements, int(i)
+}
+
+func (r *Runtime) VisitIndexExpr(node *ast.IndexExpr) any {
+	elements, i := r.elements(node, node.Expression, node.Index)
+	return elements[i]
+}
+
+func (r *Runtime) VisitSetIndexExpr(node *ast.SetIndexExpr) any {
+	// The analyzer guarantees a variable base, so the elements are modified in place
+	elements, i := r.elements(node, node.Expression, node.Index)
+
+	val := node.Value.Visit(r)
+
+	if operator, ok := scanner.CompoundOperator(node.Operator.Id); ok {
+		val = r.binary(node, operator, elements[i], val)
+	}
+
+	elements[i] = copyValue(val)
+	return val
+}
+
+func (r *Runtime) VisitCastExpr(node *ast.CastExpr) any {
+	return convert(node.Expression.Visit(r), node.TargetType)
 }
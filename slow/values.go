@@ -0,0 +1,138 @@
+package slow
+
+import (
+	"breeze/ast"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Values are numbers, bool, string, []any for slices and the types below
+
+// structValue is a struct instance. Structs have value semantics and are copied whenever they are stored.
+type structValue struct {
+	Name   string
+	Fields map[string]any
+}
+
+// arrayValue is a fixed array instance. Slices are Go slices, which already share their elements like Breeze slices.
+type arrayValue struct {
+	Elements []any
+}
+
+// functionValue is a declared function together with the environment it was declared in
+type functionValue struct {
+	Declaration *ast.FunctionDecl
+	Closure     *Environment
+}
+
+type builtinValue func(arguments []any) any
+
+// Statements return signals to unwind the tree up to the loop or call handling them
+
+type returnSignal struct {
+	Value any
+}
+
+type breakSignal struct{}
+
+type continueSignal struct{}
+
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case *structValue:
+		fields := make(map[string]any, len(v.Fields))
+		for name, field := range v.Fields {
+			fields[name] = copyValue(field)
+		}
+		return &structValue{Name: v.Name, Fields: fields}
+	case *arrayValue:
+		elements := make([]any, len(v.Elements))
+		for i, element := range v.Elements {
+			elements[i] = copyValue(element)
+		}
+		return &arrayValue{Elements: elements}
+	}
+	return value
+}
+
+// appendValue grows full slices like the other backends, so appended slices share elements in the same cases
+func appendValue(elements []any, value any) []any {
+	if len(elements) == cap(elements) {
+		capacity := 2 * cap(elements)
+		if capacity < 4 {
+			capacity = 4
+		}
+		grown := make([]any, len(elements), capacity)
+		copy(grown, elements)
+		elements = grown
+	}
+	return append(elements, value)
+}
+
+// format prints value like the debug statement of the C backend
+func format(value any) string {
+	switch v := value.(type) {
+	case int8, int16, int32, int64:
+		return strconv.FormatInt(convert(v, "i64").(int64), 10)
+	case uint8, uint16, uint32, uint64, uint:
+		return strconv.FormatUint(convert(v, "u64").(uint64), 10)
+	case float32, float64:
+		// Matches %g of printf, which prints float32 values as double as well
+		return formatFloat(convert(v, "f64").(float64))
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return "<value>"
+}
+
+// formatFloat prints value like %g of printf. NaN is printed without sign, which depends on the platform in C.
+func formatFloat(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "nan"
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(value, 'g', 6, 64)
+}
+
+// Format prints value like format and also structs, arrays and slices, whose strings are quoted
+func (r *Runtime) Format(value any) string {
+	switch v := value.(type) {
+	case *structValue:
+		declaration, ok := r.structs[v.Name]
+		if !ok {
+			return v.Name + " {}"
+		}
+		fields := make([]string, 0, len(declaration.FieldName))
+		for _, name := range declaration.FieldName {
+			fields = append(fields, name+": "+r.element(v.Fields[name]))
+		}
+		return v.Name + " { " + strings.Join(fields, ", ") + " }"
+	case *arrayValue:
+		return r.list(v.Elements)
+	case []any:
+		return r.list(v)
+	}
+	return format(value)
+}
+
+func (r *Runtime) list(values []any) string {
+	elements := make([]string, 0, len(values))
+	for _, element := range values {
+		elements = append(elements, r.element(element))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (r *Runtime) element(value any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return r.Format(value)
+}
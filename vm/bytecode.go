@@ -0,0 +1,146 @@
+package vm
+
+import (
+	"breeze/common"
+)
+
+type Opcode uint8
+
+//goland:noinspection GoCommentStart
+const (
+	OpConstant Opcode = iota // Push constant A
+	OpPop
+	OpDup
+	OpDupTwo // Duplicate the two values on top of the stack
+	OpCopy   // Replace the struct or array on top of the stack by a copy
+
+	// Variables
+	OpGetLocal // Push local slot A
+	OpSetLocal // Pop into local slot A
+	OpGetGlobal
+	OpSetGlobal
+
+	// Arithmetic
+	OpAddInt
+	OpSubtractInt
+	OpMultiplyInt
+	OpDivideInt
+	OpDivideUint
+	OpRemainderInt
+	OpRemainderUint
+	OpNegateInt
+	OpAddFloat
+	OpSubtractFloat
+	OpMultiplyFloat
+	OpDivideFloat
+	OpNegateFloat
+	OpConcat
+	OpNot
+
+	// Bitwise, shifts check that the count is below the width A
+	OpAnd
+	OpOr
+	OpXor
+	OpComplement
+	OpShiftLeft
+	OpShiftRightInt
+	OpShiftRightUint
+
+	// Conversions, integers narrower than 64 bits are kept sign or zero extended and f32 is kept rounded in a float
+	OpWrapSigned   // Truncate to A bits and sign extend
+	OpWrapUnsigned // Truncate to A bits
+	OpRoundFloat32
+	OpIntToFloat
+	OpUintToFloat
+	OpFloatToInt  // Saturate to A bits
+	OpFloatToUint // Saturate to A bits
+
+	// Comparative, bools compare like ints
+	OpEqualInt
+	OpNotEqualInt
+	OpLowerInt
+	OpGreaterInt
+	OpLowerEqualInt
+	OpGreaterEqualInt
+	OpLowerUint
+	OpGreaterUint
+	OpLowerEqualUint
+	OpGreaterEqualUint
+	OpEqualFloat
+	OpNotEqualFloat
+	OpLowerFloat
+	OpGreaterFloat
+	OpLowerEqualFloat
+	OpGreaterEqualFloat
+	OpEqualString
+	OpNotEqualString
+
+	// Control flow, A is the target instruction
+	OpJump
+	OpJumpIfFalse // Pop condition
+	OpCall        // Call function A
+	OpReturn      // Return the value on top of the stack
+	OpReturnVoid
+
+	// Structs, arrays and slices
+	OpStruct   // Pop A fields into a new struct
+	OpGetField // Replace struct by field A
+	OpSetField // Pop value and struct, set field A
+	OpArray    // Pop A elements into a new array
+	OpSlice    // Pop A elements into a new slice
+	OpIndex    // Pop index and list, push element
+	OpSetIndex // Pop value, index and list, set element
+	OpLenString
+	OpLenList
+	OpAppend
+
+	// Debug statements, A is the constant of the location
+	OpDebugInt
+	OpDebugUint
+	OpDebugFloat
+	OpDebugBool
+	OpDebugString
+)
+
+// Instruction packs the opcode into the low 8 bits and the operand A into the high 24 bits
+type Instruction uint32
+
+const maxOperand = 1<<24 - 1
+
+func encode(op Opcode, a int) Instruction {
+	return Instruction(uint32(a)<<8 | uint32(op))
+}
+
+func (i Instruction) Op() Opcode {
+	return Opcode(i & 0xff)
+}
+
+func (i Instruction) A() int {
+	return int(i >> 8)
+}
+
+// Function is the bytecode of a function. Parameters are the first local slots.
+type Function struct {
+	Name       string
+	Parameters int
+	Locals     int
+	// Upper bound of the values above the locals
+	MaxStack int
+	Code     []Instruction
+	// Source position of every instruction for runtime errors
+	Positions []common.Position
+}
+
+// Program is the compiled form of a source file
+type Program struct {
+	File      common.SourceFile
+	Constants []Value
+	Functions []*Function
+	Globals   int
+	// Runs the top level statements
+	Init *Function
+	// Index of main in Functions, -1 if not declared
+	Main int
+	// main returns an int exit code
+	MainReturns bool
+}
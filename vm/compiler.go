@@ -0,0 +1,1145 @@
+package vm
+
+import (
+	"breeze/ast"
+	"breeze/common"
+	"breeze/scanner"
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// Language is in development. Many features may change.
+// Bytecode compiler, resolves variables to slots and picks typed instructions with the types of the analyzer.
+
+type compiler struct {
+	ast.Visitor
+	program   *Program
+	structs   map[string]*ast.StructDecl
+	functions map[string]int
+	signature map[string]*ast.FunctionDecl
+	globals   map[string]global
+	constants map[constantKey]int
+	fn        *functionState
+	err       error
+}
+
+type global struct {
+	index    int
+	typeName string
+}
+
+type local struct {
+	name     string
+	typeName string
+	depth    int
+}
+
+type loop struct {
+	// Jumps to patch with the target of continue, which follows the body in for loops
+	continues []int
+	// Jumps to patch with the end of the loop
+	breaks []int
+}
+
+// functionState tracks the function that is compiled right now
+type functionState struct {
+	function *Function
+	locals   []local
+	depth    int
+	loops    []*loop
+	// Values on the stack above the locals
+	stack int
+}
+
+type constantKey struct {
+	bits uint64
+	text string
+	// Ints and floats with the same bits are different constants
+	isFloat bool
+}
+
+// CompileError is returned for programs the analyzer accepts but the bytecode can not represent
+type CompileError struct {
+	Location string
+	Message  string
+}
+
+func (e *CompileError) Error() string {
+	return e.Location + e.Message
+}
+
+// Compile translates analyzed nodes to bytecode
+func Compile(file common.SourceFile, nodes []ast.Node) (*Program, error) {
+	c := &compiler{
+		program:   &Program{File: file, Main: -1},
+		structs:   make(map[string]*ast.StructDecl),
+		functions: make(map[string]int),
+		signature: make(map[string]*ast.FunctionDecl),
+		globals:   make(map[string]global),
+		constants: make(map[constantKey]int),
+	}
+
+	// Structs and functions can be used before their declaration
+	for _, node := range nodes {
+		switch declaration := node.(type) {
+		case *ast.StructDecl:
+			c.structs[declaration.Identifier] = declaration
+		case *ast.FunctionDecl:
+			c.functions[declaration.Identifier] = len(c.program.Functions)
+			c.signature[declaration.Identifier] = declaration
+			c.program.Functions = append(c.program.Functions, &Function{
+				Name:       declaration.Identifier,
+				Parameters: len(declaration.ParamName),
+			})
+		}
+	}
+
+	c.program.Init = &Function{Name: "<init>"}
+	c.fn = &functionState{function: c.program.Init}
+
+	for _, node := range nodes {
+		_ = node.Visit(c)
+	}
+	c.emit(nil, OpReturnVoid, 0)
+	c.finish()
+
+	if index, ok := c.functions["main"]; ok {
+		c.program.Main = index
+		c.program.MainReturns = !isVoid(c.signature["main"].ReturnType)
+	}
+
+	return c.program, c.err
+}
+
+func isVoid(typeName string) bool {
+	return len(typeName) == 0 || typeName == "void"
+}
+
+func (c *compiler) fail(at ast.Node, message string) {
+	if c.err == nil {
+		c.err = &CompileError{Location: c.location(at.GetToken()), Message: message}
+	}
+}
+
+// location formats the position of token like the C backend does
+func (c *compiler) location(token scanner.Token) string {
+	position := token.Position
+	return fmt.Sprintf("[%s:%d:%d] ", filepath.Base(c.program.File.Path), position.Line, position.Column)
+}
+
+// stackEffect is the number of values op pushes minus the number it pops
+func (c *compiler) stackEffect(op Opcode, a int) int {
+	switch op {
+	case OpConstant, OpDup, OpGetLocal, OpGetGlobal:
+		return 1
+	case OpDupTwo:
+		return 2
+	case OpCopy, OpNegateInt, OpNegateFloat, OpNot, OpGetField, OpLenString, OpLenList, OpJump, OpReturnVoid:
+		return 0
+	case OpWrapSigned, OpWrapUnsigned, OpRoundFloat32, OpIntToFloat, OpUintToFloat, OpFloatToInt, OpFloatToUint, OpComplement:
+		return 0
+	case OpStruct, OpArray, OpSlice:
+		return 1 - a
+	case OpCall:
+		callee := c.program.Functions[a]
+		if isVoid(c.signature[callee.Name].ReturnType) {
+			return -callee.Parameters
+		}
+		return 1 - callee.Parameters
+	case OpSetField:
+		return -1
+	case OpSetIndex:
+		return -2
+	}
+	// Binary operations, stores, conditional jumps, returns and debug statements pop one value
+	return -1
+}
+
+// emit appends an instruction at the position of node and returns its address
+func (c *compiler) emit(at ast.Node, op Opcode, a int) int {
+	if a > maxOperand {
+		c.fail(at, "program is too large for the bytecode")
+	}
+
+	function := c.fn.function
+	function.Code = append(function.Code, encode(op, a))
+
+	position := common.InitPosition()
+	if at != nil {
+		position = at.GetToken().Position
+	}
+	function.Positions = append(function.Positions, position)
+
+	c.fn.stack += c.stackEffect(op, a)
+	// Branches are counted as if they were executed one after another, which overestimates
+	if c.fn.stack > function.MaxStack {
+		function.MaxStack = c.fn.stack
+	}
+
+	return len(function.Code) - 1
+}
+
+// patch points the jump at address to the next instruction
+func (c *compiler) patch(address int) {
+	c.patchTo(address, len(c.fn.function.Code))
+}
+
+// patchTo points the jump at address to target
+func (c *compiler) patchTo(address int, target int) {
+	code := c.fn.function.Code
+	code[address] = encode(code[address].Op(), target)
+}
+
+// finish completes the function state after its code is emitted
+func (c *compiler) finish() {
+	if len(c.fn.locals) > c.fn.function.Locals {
+		c.fn.function.Locals = len(c.fn.locals)
+	}
+}
+
+func (c *compiler) constant(key constantKey, value Value) int {
+	if index, ok := c.constants[key]; ok {
+		return index
+	}
+	index := len(c.program.Constants)
+	c.program.Constants = append(c.program.Constants, value)
+	c.constants[key] = index
+	return index
+}
+
+func (c *compiler) emitConstant(at ast.Node, key constantKey, value Value) {
+	c.emit(at, OpConstant, c.constant(key, value))
+}
+
+// zero builds the value of uninitialized variables
+func (c *compiler) zero(typeName string) Value {
+	if declaration, ok := c.structs[typeName]; ok {
+		fields := make([]Value, len(declaration.FieldType))
+		for i, fieldType := range declaration.FieldType {
+			fields[i] = c.zero(fieldType)
+		}
+		return Value{Ref: &structObject{Fields: fields}}
+	}
+	if element, length, ok := ast.ArrayType(typeName); ok {
+		elements := make([]Value, length)
+		for i := range elements {
+			elements[i] = c.zero(element)
+		}
+		return Value{Ref: &arrayObject{Elements: elements}}
+	}
+	if _, ok := ast.SliceType(typeName); ok {
+		return Value{Ref: &sliceObject{}}
+	}
+	if typeName == "string" {
+		return stringValue("")
+	}
+	return Value{}
+}
+
+// emitZero pushes a fresh zero value of typeName
+func (c *compiler) emitZero(at ast.Node, typeName string) {
+	c.emitConstant(at, constantKey{text: "zero " + typeName}, c.zero(typeName))
+	if c.isCopied(typeName) {
+		// The constant is a prototype shared by all zero values
+		c.emit(at, OpCopy, 0)
+	}
+}
+
+// isCopied reports if values of typeName are copied when they are stored
+func (c *compiler) isCopied(typeName string) bool {
+	if _, ok := c.structs[typeName]; ok {
+		return true
+	}
+	_, _, ok := ast.ArrayType(typeName)
+	return ok
+}
+
+// value compiles a value that is stored, values of variables are copied so the variable is not modified through it
+func (c *compiler) value(node ast.Node) string {
+	typeName := node.Visit(c).(string)
+	switch node.GetId() {
+	case ast.IdentifierLitId, ast.GetId, ast.IndexId:
+		if c.isCopied(typeName) {
+			c.emit(node, OpCopy, 0)
+		}
+	}
+	return typeName
+}
+
+func (c *compiler) field(structType string, name string) (int, string) {
+	declaration := c.structs[structType]
+	for i, fieldName := range declaration.FieldName {
+		if fieldName == name {
+			return i, declaration.FieldType[i]
+		}
+	}
+	return 0, ""
+}
+
+// Variables
+
+// local returns the slot of the innermost local called name
+func (c *compiler) local(name string) (int, bool) {
+	for i := len(c.fn.locals) - 1; i >= 0; i-- {
+		if c.fn.locals[i].name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// resolve returns the slot of a local or the index of a global
+func (c *compiler) resolve(name string) (slot int, typeName string, isLocal bool) {
+	if slot, ok := c.local(name); ok {
+		return slot, c.fn.locals[slot].typeName, true
+	}
+	g := c.globals[name]
+	return g.index, g.typeName, false
+}
+
+func (c *compiler) load(at ast.Node, name string) string {
+	slot, typeName, isLocal := c.resolve(name)
+	if isLocal {
+		c.emit(at, OpGetLocal, slot)
+	} else {
+		c.emit(at, OpGetGlobal, slot)
+	}
+	return typeName
+}
+
+func (c *compiler) store(at ast.Node, name string) {
+	slot, _, isLocal := c.resolve(name)
+	if isLocal {
+		c.emit(at, OpSetLocal, slot)
+	} else {
+		c.emit(at, OpSetGlobal, slot)
+	}
+}
+
+func (c *compiler) VisitLetDecl(node *ast.LetDecl) any {
+	c.emitZero(node, node.Type)
+
+	// Variables outside of functions and blocks are globals
+	if c.fn.function == c.program.Init && c.fn.depth == 0 {
+		c.globals[node.Identifier] = global{index: c.program.Globals, typeName: node.Type}
+		c.program.Globals++
+	} else {
+		c.fn.locals = append(c.fn.locals, local{name: node.Identifier, typeName: node.Type, depth: c.fn.depth})
+		c.finish()
+	}
+
+	c.store(node, node.Identifier)
+	return nil
+}
+
+func (c *compiler) VisitIdentifierLitExpr(node *ast.IdentifierLitExpr) any {
+	_, isLocal := c.local(node.Name)
+	_, isGlobal := c.globals[node.Name]
+	if !isLocal && !isGlobal {
+		c.fail(node, "functions can only be called")
+		return "undef_type"
+	}
+	return c.load(node, node.Name)
+}
+
+// arithmetic returns the instruction of a binary operator on operands of typeName
+func arithmetic(operator scanner.TokenId, typeName string) (Opcode, bool) {
+	// Numeric types share the instructions of their kind
+	if number, ok := ast.NumberType(typeName); ok {
+		typeName = [...]string{ast.Signed: "int", ast.Unsigned: "uint", ast.Floating: "float"}[number.Kind]
+	}
+
+	switch typeName {
+	case "uint":
+		// Only division, ordering and shifting right differ from ints
+		switch operator {
+		case scanner.Slash:
+			return OpDivideUint, true
+		case scanner.Percent:
+			return OpRemainderUint, true
+		case scanner.GreaterGreater:
+			return OpShiftRightUint, true
+		case scanner.Lower:
+			return OpLowerUint, true
+		case scanner.Greater:
+			return OpGreaterUint, true
+		case scanner.LowerEquals:
+			return OpLowerEqualUint, true
+		case scanner.GreaterEquals:
+			return OpGreaterEqualUint, true
+		}
+		return arithmetic(operator, "int")
+	case "int", "bool":
+		switch operator {
+		case scanner.Plus:
+			return OpAddInt, true
+		case scanner.Minus:
+			return OpSubtractInt, true
+		case scanner.Star:
+			return OpMultiplyInt, true
+		case scanner.Slash:
+			return OpDivideInt, true
+		case scanner.Percent:
+			return OpRemainderInt, true
+		case scanner.And:
+			return OpAnd, true
+		case scanner.Pipe:
+			return OpOr, true
+		case scanner.Caret:
+			return OpXor, true
+		case scanner.LowerLower:
+			return OpShiftLeft, true
+		case scanner.GreaterGreater:
+			return OpShiftRightInt, true
+		case scanner.EqualsEquals:
+			return OpEqualInt, true
+		case scanner.BangEquals:
+			return OpNotEqualInt, true
+		case scanner.Lower:
+			return OpLowerInt, true
+		case scanner.Greater:
+			return OpGreaterInt, true
+		case scanner.LowerEquals:
+			return OpLowerEqualInt, true
+		case scanner.GreaterEquals:
+			return OpGreaterEqualInt, true
+		}
+	case "float":
+		switch operator {
+		case scanner.Plus:
+			return OpAddFloat, true
+		case scanner.Minus:
+			return OpSubtractFloat, true
+		case scanner.Star:
+			return OpMultiplyFloat, true
+		case scanner.Slash:
+			return OpDivideFloat, true
+		case scanner.EqualsEquals:
+			return OpEqualFloat, true
+		case scanner.BangEquals:
+			return OpNotEqualFloat, true
+		case scanner.Lower:
+			return OpLowerFloat, true
+		case scanner.Greater:
+			return OpGreaterFloat, true
+		case scanner.LowerEquals:
+			return OpLowerEqualFloat, true
+		case scanner.GreaterEquals:
+			return OpGreaterEqualFloat, true
+		}
+	case "string":
+		switch operator {
+		case scanner.Plus:
+			return OpConcat, true
+		case scanner.EqualsEquals:
+			return OpEqualString, true
+		case scanner.BangEquals:
+			return OpNotEqualString, true
+		}
+	}
+	return 0, false
+}
+
+// operation emits a binary operator or the operator applied by a compound assignment
+func (c *compiler) operation(at ast.Node, operator scanner.Token, typeName string) {
+	id := operator.Id
+	if binary, ok := scanner.CompoundOperator(id); ok {
+		id = binary
+	}
+
+	op, ok := arithmetic(id, typeName)
+	if !ok {
+		c.fail(at, fmt.Sprintf("operator %s is not supported on %s", operator.Lexeme, typeName))
+		return
+	}
+
+	a := 0
+	if id == scanner.LowerLower || id == scanner.GreaterGreater {
+		number, _ := ast.NumberType(typeName)
+		a = number.Bits
+	}
+	c.emit(at, op, a)
+
+	if !isComparison(id) {
+		c.wrap(at, typeName)
+	}
+}
+
+func isComparison(operator scanner.TokenId) bool {
+	switch operator {
+	case scanner.EqualsEquals, scanner.BangEquals, scanner.Lower, scanner.Greater, scanner.LowerEquals, scanner.GreaterEquals:
+		return true
+	}
+	return false
+}
+
+// wrap brings the result of an operation on typeName back to its width
+func (c *compiler) wrap(at ast.Node, typeName string) {
+	number, ok := ast.NumberType(typeName)
+	if !ok || number.Bits == 64 {
+		return
+	}
+	switch number.Kind {
+	case ast.Signed:
+		c.emit(at, OpWrapSigned, number.Bits)
+	case ast.Unsigned:
+		c.emit(at, OpWrapUnsigned, number.Bits)
+	case ast.Floating:
+		c.emit(at, OpRoundFloat32, 0)
+	}
+}
+
+// Assignments leave the assigned value on the stack if keep is set, expression statements do not need it
+
+func (c *compiler) assign(node *ast.AssignExpr, keep bool) string {
+	name := node.Name.Lexeme
+
+	var typeName string
+	if node.Operator.Id == scanner.Equals {
+		typeName = c.value(node.Value)
+	} else {
+		typeName = c.load(node, name)
+		_ = node.Value.Visit(c)
+		c.operation(node, node.Operator, typeName)
+	}
+
+	if keep {
+		c.emit(node, OpDup, 0)
+	}
+	c.store(node, name)
+	return typeName
+}
+
+func (c *compiler) setField(node *ast.SetExpr, keep bool) string {
+	structType := node.Expression.Visit(c).(string)
+	index, typeName := c.field(structType, node.Name.Lexeme)
+
+	if node.Operator.Id == scanner.Equals {
+		_ = c.value(node.Value)
+	} else {
+		c.emit(node, OpDup, 0)
+		c.emit(node, OpGetField, index)
+		_ = node.Value.Visit(c)
+		c.operation(node, node.Operator, typeName)
+	}
+
+	c.emit(node, OpSetField, index)
+	if !keep {
+		c.emit(node, OpPop, 0)
+	}
+	return typeName
+}
+
+func (c *compiler) setIndex(node *ast.SetIndexExpr, keep bool) string {
+	_ = node.Expression.Visit(c)
+	_ = node.Index.Visit(c)
+
+	if node.Operator.Id == scanner.Equals {
+		_ = c.value(node.Value)
+	} else {
+		c.emit(node, OpDupTwo, 0)
+		c.emit(node, OpIndex, 0)
+		_ = node.Value.Visit(c)
+		c.operation(node, node.Operator, elementType(node.Type))
+	}
+
+	c.emit(node, OpSetIndex, 0)
+	if !keep {
+		c.emit(node, OpPop, 0)
+	}
+	return elementType(node.Type)
+}
+
+func (c *compiler) VisitAssignExpr(node *ast.AssignExpr) any {
+	return c.assign(node, true)
+}
+
+func (c *compiler) VisitSetExpr(node *ast.SetExpr) any {
+	return c.setField(node, true)
+}
+
+func (c *compiler) VisitSetIndexExpr(node *ast.SetIndexExpr) any {
+	return c.setIndex(node, true)
+}
+
+// Statements
+
+func (c *compiler) VisitExprStmt(node *ast.ExprStmt) any {
+	c.discard(node, node.Expression)
+	return nil
+}
+
+// discard compiles an expression whose value is not used
+func (c *compiler) discard(at ast.Node, expression ast.Node) {
+	switch expression := expression.(type) {
+	case *ast.AssignExpr:
+		_ = c.assign(expression, false)
+	case *ast.SetExpr:
+		_ = c.setField(expression, false)
+	case *ast.SetIndexExpr:
+		_ = c.setIndex(expression, false)
+	case *ast.MatchExpr:
+		_ = c.match(expression, false)
+	default:
+		if !isVoid(expression.Visit(c).(string)) {
+			c.emit(at, OpPop, 0)
+		}
+	}
+}
+
+func (c *compiler) VisitDebugStmt(node *ast.DebugStmt) any {
+	_ = node.Expression.Visit(c)
+
+	var op Opcode
+	number, isNumber := ast.NumberType(node.Type)
+	switch {
+	case isNumber:
+		op = [...]Opcode{ast.Signed: OpDebugInt, ast.Unsigned: OpDebugUint, ast.Floating: OpDebugFloat}[number.Kind]
+	case node.Type == "bool":
+		op = OpDebugBool
+	case node.Type == "string":
+		op = OpDebugString
+	default:
+		c.fail(node, fmt.Sprintf("debug is not supported on %s", node.Type))
+		return nil
+	}
+
+	location := c.location(node.GetToken())
+	c.emit(node, op, c.constant(constantKey{text: "string " + location}, stringValue(location)))
+	return nil
+}
+
+func (c *compiler) VisitBlockStmt(node *ast.BlockStmt) any {
+	for _, n := range node.Nodes {
+		_ = n.Visit(c)
+	}
+	return nil
+}
+
+func (c *compiler) VisitClosureStmt(node *ast.ClosureStmt) any {
+	c.fn.depth++
+	_ = node.Block.Visit(c)
+	c.leave()
+	return nil
+}
+
+// leave ends a block, slots of the block are reused by following declarations
+func (c *compiler) leave() {
+	c.fn.depth--
+
+	locals := c.fn.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > c.fn.depth {
+		locals = locals[:len(locals)-1]
+	}
+	c.fn.locals = locals
+}
+
+// declareLocal adds a local to the current block and stores the value on top of the stack in it
+func (c *compiler) declareLocal(at ast.Node, name string, typeName string) {
+	c.fn.locals = append(c.fn.locals, local{name: name, typeName: typeName, depth: c.fn.depth})
+	c.finish()
+	c.store(at, name)
+}
+
+func (c *compiler) VisitConditionalStmt(node *ast.ConditionalStmt) any {
+	_ = node.Condition.Visit(c)
+	otherwise := c.emit(node, OpJumpIfFalse, 0)
+	_ = node.Statement.Visit(c)
+
+	if node.ElseStatement == nil {
+		c.patch(otherwise)
+		return nil
+	}
+
+	end := c.emit(node, OpJump, 0)
+	c.patch(otherwise)
+	_ = node.ElseStatement.Visit(c)
+	c.patch(end)
+	return nil
+}
+
+func (c *compiler) VisitWhileStmt(node *ast.WhileStmt) any {
+	l := &loop{}
+	c.fn.loops = append(c.fn.loops, l)
+
+	start := len(c.fn.function.Code)
+	_ = node.Condition.Visit(c)
+	exit := c.emit(node, OpJumpIfFalse, 0)
+	_ = node.Statement.Visit(c)
+	c.emit(node, OpJump, start)
+
+	c.patch(exit)
+	c.endLoop(l, start)
+	return nil
+}
+
+// endLoop patches the jumps of the loop that is left, continue jumps to target
+func (c *compiler) endLoop(l *loop, target int) {
+	for _, address := range l.continues {
+		c.patchTo(address, target)
+	}
+	for _, address := range l.breaks {
+		c.patch(address)
+	}
+	c.fn.loops = c.fn.loops[:len(c.fn.loops)-1]
+}
+
+func (c *compiler) VisitForStmt(node *ast.ForStmt) any {
+	// Variables of the initializer are only visible in the loop
+	c.fn.depth++
+	if node.Initializer != nil {
+		_ = node.Initializer.Visit(c)
+	}
+
+	l := &loop{}
+	c.fn.loops = append(c.fn.loops, l)
+
+	start := len(c.fn.function.Code)
+	exit := -1
+	if node.Condition != nil {
+		_ = node.Condition.Visit(c)
+		exit = c.emit(node, OpJumpIfFalse, 0)
+	}
+	_ = node.Statement.Visit(c)
+
+	increment := len(c.fn.function.Code)
+	if node.Increment != nil {
+		c.discard(node, node.Increment)
+	}
+	c.emit(node, OpJump, start)
+
+	if exit >= 0 {
+		c.patch(exit)
+	}
+	c.endLoop(l, increment)
+	c.leave()
+	return nil
+}
+
+// Hidden locals of for in loops have names that are no identifiers
+const (
+	forIndex      = "for index"
+	forEnd        = "for end"
+	forCollection = "for collection"
+)
+
+// VisitForInStmt evaluates the range or collection once and binds the name to a copy of the value in every iteration
+func (c *compiler) VisitForInStmt(node *ast.ForInStmt) any {
+	c.fn.depth++
+
+	indexType := "int"
+	element := ""
+	if node.End != nil {
+		indexType = node.Type
+		_ = node.Expression.Visit(c)
+		c.declareLocal(node, forIndex, indexType)
+		_ = node.End.Visit(c)
+		c.declareLocal(node, forEnd, indexType)
+	} else {
+		element, _ = ast.SliceType(node.Type)
+		if arrayElement, _, isArray := ast.ArrayType(node.Type); isArray {
+			element = arrayElement
+		}
+		_ = c.value(node.Expression)
+		c.declareLocal(node, forCollection, node.Type)
+		c.emitConstant(node, constantKey{bits: 0}, intValue(0))
+		c.declareLocal(node, forIndex, indexType)
+	}
+
+	l := &loop{}
+	c.fn.loops = append(c.fn.loops, l)
+
+	start := len(c.fn.function.Code)
+	_ = c.load(node, forIndex)
+	if node.End != nil {
+		_ = c.load(node, forEnd)
+	} else {
+		_ = c.load(node, forCollection)
+		c.emit(node, OpLenList, 0)
+	}
+	lower, _ := arithmetic(scanner.Lower, indexType)
+	c.emit(node, lower, 0)
+	exit := c.emit(node, OpJumpIfFalse, 0)
+
+	c.fn.depth++
+	if node.End != nil {
+		_ = c.load(node, forIndex)
+		c.declareLocal(node, node.Name.Lexeme, indexType)
+	} else {
+		_ = c.load(node, forCollection)
+		_ = c.load(node, forIndex)
+		c.emit(node, OpIndex, 0)
+		if c.isCopied(element) {
+			c.emit(node, OpCopy, 0)
+		}
+		c.declareLocal(node, node.Name.Lexeme, element)
+	}
+	_ = node.Statement.Visit(c)
+	c.leave()
+
+	// The index stays below the end, so it does not wrap around
+	increment := len(c.fn.function.Code)
+	_ = c.load(node, forIndex)
+	c.emitConstant(node, constantKey{bits: 1}, intValue(1))
+	c.emit(node, OpAddInt, 0)
+	c.store(node, forIndex)
+	c.emit(node, OpJump, start)
+
+	c.patch(exit)
+	c.endLoop(l, increment)
+	c.leave()
+	return nil
+}
+
+// Hidden local of the matched value
+const matchValue = "match value"
+
+func (c *compiler) VisitMatchExpr(node *ast.MatchExpr) any {
+	return c.match(node, true)
+}
+
+// match compares the matched value with the patterns of each arm in order and runs the first arm with an equal
+// pattern. With keep set the arms are values and the result is left on the stack.
+func (c *compiler) match(node *ast.MatchExpr, keep bool) string {
+	c.fn.depth++
+	_ = node.Value.Visit(c)
+	c.declareLocal(node, matchValue, node.Type)
+	equal, _ := arithmetic(scanner.EqualsEquals, node.Type)
+
+	ends := make([]int, 0, len(node.Arms))
+	for i, arm := range node.Arms {
+		// Alternatives jump to the arm if equal, the last one jumps to the next arm if not
+		bodies := make([]int, 0)
+		next := -1
+		alternatives := node.Patterns[i]
+		for j, pattern := range alternatives {
+			_ = c.load(pattern, matchValue)
+			_ = pattern.Visit(c)
+			c.emit(pattern, equal, 0)
+			miss := c.emit(pattern, OpJumpIfFalse, 0)
+			if j == len(alternatives)-1 {
+				next = miss
+				break
+			}
+			bodies = append(bodies, c.emit(pattern, OpJump, 0))
+			c.patch(miss)
+		}
+		for _, address := range bodies {
+			c.patch(address)
+		}
+
+		switch {
+		case keep:
+			_ = c.value(arm)
+			if i < len(node.Arms)-1 {
+				// Only one arm runs, so the stack holds a single result
+				c.fn.stack--
+			}
+		case arm.GetId() == ast.ClosureId:
+			_ = arm.Visit(c)
+		default:
+			c.discard(node, arm)
+		}
+
+		if i < len(node.Arms)-1 {
+			ends = append(ends, c.emit(node, OpJump, 0))
+		}
+		if next >= 0 {
+			c.patch(next)
+		}
+	}
+	for _, address := range ends {
+		c.patch(address)
+	}
+
+	c.leave()
+	if !keep {
+		return "void"
+	}
+	return node.ResultType
+}
+
+func (c *compiler) VisitBreakStmt(node *ast.BreakStmt) any {
+	if len(c.fn.loops) == 0 {
+		c.fail(node, "break outside of loop")
+		return nil
+	}
+	l := c.fn.loops[len(c.fn.loops)-1]
+	l.breaks = append(l.breaks, c.emit(node, OpJump, 0))
+	return nil
+}
+
+func (c *compiler) VisitContinueStmt(node *ast.ContinueStmt) any {
+	if len(c.fn.loops) == 0 {
+		c.fail(node, "continue outside of loop")
+		return nil
+	}
+	l := c.fn.loops[len(c.fn.loops)-1]
+	l.continues = append(l.continues, c.emit(node, OpJump, 0))
+	return nil
+}
+
+// Functions
+
+func (c *compiler) VisitFunctionDecl(node *ast.FunctionDecl) any {
+	function := c.program.Functions[c.functions[node.Identifier]]
+
+	before := c.fn
+	c.fn = &functionState{function: function}
+	for i, name := range node.ParamName {
+		c.fn.locals = append(c.fn.locals, local{name: name, typeName: node.ParamType[i]})
+	}
+	c.finish()
+
+	_ = node.Closure.Visit(c)
+
+	// Functions without a return at the end
+	if isVoid(node.ReturnType) {
+		c.emit(node, OpReturnVoid, 0)
+	} else {
+		c.emitZero(node, node.ReturnType)
+		c.emit(node, OpReturn, 0)
+	}
+
+	c.fn = before
+	return nil
+}
+
+func (c *compiler) VisitReturnStmt(node *ast.ReturnStmt) any {
+	if node.Expression == nil {
+		c.emit(node, OpReturnVoid, 0)
+		return nil
+	}
+	_ = c.value(node.Expression)
+	c.emit(node, OpReturn, 0)
+	return nil
+}
+
+func (c *compiler) VisitCallExpr(node *ast.CallExpr) any {
+	callee, ok := node.Expression.(*ast.IdentifierLitExpr)
+	if !ok {
+		c.fail(node, "called value is not a function")
+		return "undef_type"
+	}
+
+	index, isFunction := c.functions[callee.Name]
+	if !isFunction {
+		return c.builtin(node, callee.Name)
+	}
+
+	for _, argument := range node.Arguments {
+		_ = c.value(argument)
+	}
+	c.emit(node, OpCall, index)
+
+	returnType := c.signature[callee.Name].ReturnType
+	if isVoid(returnType) {
+		return "void"
+	}
+	return returnType
+}
+
+func (c *compiler) builtin(node *ast.CallExpr, name string) string {
+	switch name {
+	case "len":
+		_ = node.Arguments[0].Visit(c)
+		if node.Type == "string" {
+			c.emit(node, OpLenString, 0)
+		} else {
+			c.emit(node, OpLenList, 0)
+		}
+		return "int"
+	case "append":
+		_ = node.Arguments[0].Visit(c)
+		_ = c.value(node.Arguments[1])
+		c.emit(node, OpAppend, 0)
+		return node.Type
+	}
+
+	c.fail(node, fmt.Sprintf("unknown function %s", name))
+	return "undef_type"
+}
+
+// Expressions return their type name
+
+func (c *compiler) VisitBinaryExpr(node *ast.BinaryExpr) any {
+	// Short circuit logical operators
+	switch node.Operator.Id {
+	case scanner.AndAnd:
+		_ = node.Left.Visit(c)
+		right := c.emit(node, OpJumpIfFalse, 0)
+		_ = node.Right.Visit(c)
+		end := c.emit(node, OpJump, 0)
+		c.patch(right)
+		c.emitConstant(node, constantKey{bits: 0}, boolValue(false))
+		c.patch(end)
+		// Only one of the operands is left on the stack
+		c.fn.stack--
+		return "bool"
+	case scanner.PipePipe:
+		_ = node.Left.Visit(c)
+		right := c.emit(node, OpJumpIfFalse, 0)
+		c.emitConstant(node, constantKey{bits: 1}, boolValue(true))
+		end := c.emit(node, OpJump, 0)
+		c.patch(right)
+		_ = node.Right.Visit(c)
+		c.patch(end)
+		c.fn.stack--
+		return "bool"
+	}
+
+	_ = node.Left.Visit(c)
+	_ = node.Right.Visit(c)
+	c.operation(node, node.Operator, node.Type)
+
+	if isComparison(node.Operator.Id) {
+		return "bool"
+	}
+	return node.Type
+}
+
+func (c *compiler) VisitUnaryExpr(node *ast.UnaryExpr) any {
+	typeName := node.Expression.Visit(c).(string)
+
+	switch node.Operator.Id {
+	case scanner.Plus:
+		break
+	case scanner.Minus:
+		if number, _ := ast.NumberType(typeName); number.Kind == ast.Floating {
+			c.emit(node, OpNegateFloat, 0)
+		} else {
+			c.emit(node, OpNegateInt, 0)
+			c.wrap(node, typeName)
+		}
+	case scanner.Bang:
+		c.emit(node, OpNot, 0)
+	case scanner.Tilde:
+		c.emit(node, OpComplement, 0)
+		c.wrap(node, typeName)
+	default:
+		c.fail(node, fmt.Sprintf("operator %s is not supported", node.Operator.Lexeme))
+	}
+
+	return typeName
+}
+
+func (c *compiler) VisitIntegerLitExpr(node *ast.IntegerLitExpr) any {
+	// The analyzer allows 1 << 63 only if negated, which wraps to itself
+	i, _ := strconv.ParseUint(node.Value, 10, 64)
+	c.emitConstant(node, constantKey{bits: i}, intValue(int64(i)))
+	if node.Type == "" {
+		return "int"
+	}
+	return node.Type
+}
+
+func (c *compiler) VisitFloatingLitExpr(node *ast.FloatingLitExpr) any {
+	f, _ := strconv.ParseFloat(node.Value, 64)
+	if node.Type == "f32" {
+		f = float64(float32(f))
+	}
+	value := floatValue(f)
+	c.emitConstant(node, constantKey{bits: value.Bits, isFloat: true}, value)
+	if node.Type == "" {
+		return "float"
+	}
+	return node.Type
+}
+
+func (c *compiler) VisitCastExpr(node *ast.CastExpr) any {
+	_ = node.Expression.Visit(c)
+
+	from, _ := ast.NumberType(node.Type)
+	to, _ := ast.NumberType(node.TargetType)
+	switch {
+	case from.Kind == ast.Floating && to.Kind != ast.Floating:
+		if to.Kind == ast.Unsigned {
+			c.emit(node, OpFloatToUint, to.Bits)
+		} else {
+			c.emit(node, OpFloatToInt, to.Bits)
+		}
+	case from.Kind == ast.Signed && to.Kind == ast.Floating:
+		c.emit(node, OpIntToFloat, 0)
+	case from.Kind == ast.Unsigned && to.Kind == ast.Floating:
+		c.emit(node, OpUintToFloat, 0)
+	}
+	// Integers already have the bits of a 64 bit integer of the target type
+	c.wrap(node, node.TargetType)
+
+	return node.TargetType
+}
+
+func (c *compiler) VisitBooleanLitExpr(node *ast.BooleanLitExpr) any {
+	value := boolValue(node.Value == "true")
+	c.emitConstant(node, constantKey{bits: value.Bits}, value)
+	return "bool"
+}
+
+func (c *compiler) VisitStringLitExpr(node *ast.StringLitExpr) any {
+	c.emitConstant(node, constantKey{text: "string " + node.Value}, stringValue(node.Value))
+	return "string"
+}
+
+func (c *compiler) VisitErrNode(node *ast.ErrNode) any {
+	c.fail(node, node.Message)
+	return "undef_type"
+}
+
+// Structs, arrays and slices
+
+func (c *compiler) VisitStructDecl(node *ast.StructDecl) any {
+	return nil
+}
+
+func (c *compiler) VisitStructLitExpr(node *ast.StructLitExpr) any {
+	declaration := c.structs[node.Identifier]
+
+	// Fields are pushed in the order of the declaration
+	for _, name := range declaration.FieldName {
+		for i, field := range node.Fields {
+			if field.Lexeme == name {
+				_ = c.value(node.Values[i])
+			}
+		}
+	}
+
+	c.emit(node, OpStruct, len(declaration.FieldName))
+	return node.Identifier
+}
+
+func (c *compiler) VisitGetExpr(node *ast.GetExpr) any {
+	structType := node.Expression.Visit(c).(string)
+	index, typeName := c.field(structType, node.Name.Lexeme)
+	c.emit(node, OpGetField, index)
+	return typeName
+}
+
+func (c *compiler) VisitArrayLitExpr(node *ast.ArrayLitExpr) any {
+	for _, value := range node.Values {
+		_ = c.value(value)
+	}
+
+	if _, isSlice := ast.SliceType(node.Type); isSlice {
+		c.emit(node, OpSlice, len(node.Values))
+	} else {
+		c.emit(node, OpArray, len(node.Values))
+	}
+	return node.Type
+}
+
+func (c *compiler) VisitIndexExpr(node *ast.IndexExpr) any {
+	_ = node.Expression.Visit(c)
+	_ = node.Index.Visit(c)
+	c.emit(node, OpIndex, 0)
+	return elementType(node.Type)
+}
+
+// elementType returns the element type of an array or slice type
+func elementType(collectionType string) string {
+	if element, _, ok := ast.ArrayType(collectionType); ok {
+		return element
+	}
+	element, _ := ast.SliceType(collectionType)
+	return element
+}
@@ -0,0 +1,131 @@
+package vm
+
+import (
+	"math"
+	"strconv"
+)
+
+// Value is an untagged value, the compiler knows its type statically.
+// Ints, floats and bools are stored in Bits, strings, structs, arrays and slices in Ref.
+type Value struct {
+	Bits uint64
+	Ref  any
+}
+
+// arrayObject is a fixed array, it is copied whenever it is stored
+type arrayObject struct {
+	Elements []Value
+}
+
+// sliceObject is a slice, copies share the elements until append has to grow them
+type sliceObject struct {
+	Elements []Value
+}
+
+type structObject struct {
+	Fields []Value
+}
+
+func intValue(i int64) Value {
+	return Value{Bits: uint64(i)}
+}
+
+func floatValue(f float64) Value {
+	return Value{Bits: math.Float64bits(f)}
+}
+
+func boolValue(b bool) Value {
+	if b {
+		return Value{Bits: 1}
+	}
+	return Value{}
+}
+
+func stringValue(s string) Value {
+	return Value{Ref: s}
+}
+
+func (v Value) Int() int64 {
+	return int64(v.Bits)
+}
+
+func (v Value) Float() float64 {
+	return math.Float64frombits(v.Bits)
+}
+
+func (v Value) Bool() bool {
+	return v.Bits != 0
+}
+
+func (v Value) String() string {
+	// Uninitialized strings are empty
+	s, _ := v.Ref.(string)
+	return s
+}
+
+// elements returns the elements of an array or slice
+func (v Value) elements() []Value {
+	if array, ok := v.Ref.(*arrayObject); ok {
+		return array.Elements
+	}
+	return v.Ref.(*sliceObject).Elements
+}
+
+// copyValue copies structs and arrays including nested ones. Slices inside are shared.
+func copyValue(v Value) Value {
+	switch object := v.Ref.(type) {
+	case *structObject:
+		fields := make([]Value, len(object.Fields))
+		for i, field := range object.Fields {
+			fields[i] = copyValue(field)
+		}
+		return Value{Ref: &structObject{Fields: fields}}
+	case *arrayObject:
+		elements := make([]Value, len(object.Elements))
+		for i, element := range object.Elements {
+			elements[i] = copyValue(element)
+		}
+		return Value{Ref: &arrayObject{Elements: elements}}
+	}
+	return v
+}
+
+// appendValue grows slices like the C backend, so both share elements in the same cases
+func appendValue(slice Value, value Value) Value {
+	elements := slice.Ref.(*sliceObject).Elements
+	if len(elements) == cap(elements) {
+		capacity := 2 * cap(elements)
+		if capacity < 4 {
+			capacity = 4
+		}
+		grown := make([]Value, len(elements), capacity)
+		copy(grown, elements)
+		elements = grown
+	}
+	return Value{Ref: &sliceObject{Elements: append(elements, value)}}
+}
+
+// format prints value like the debug statement of the C backend
+func format(value Value, op Opcode) string {
+	switch op {
+	case OpDebugInt:
+		return strconv.FormatInt(value.Int(), 10)
+	case OpDebugUint:
+		return strconv.FormatUint(value.Bits, 10)
+	case OpDebugFloat:
+		// Matches %g of printf. NaN is printed without sign, which depends on the platform in C.
+		float := value.Float()
+		switch {
+		case math.IsNaN(float):
+			return "nan"
+		case math.IsInf(float, 1):
+			return "inf"
+		case math.IsInf(float, -1):
+			return "-inf"
+		}
+		return strconv.FormatFloat(float, 'g', 6, 64)
+	case OpDebugBool:
+		return strconv.FormatBool(value.Bool())
+	}
+	return value.String()
+}
@@ -0,0 +1,415 @@
+package vm
+
+import (
+	"breeze/ast"
+	"breeze/common"
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// Language is in development. Many features may change.
+// Bytecode virtual machine, runs programs without a C compiler and much faster than the tree-walking interpreter.
+
+// maxFrames limits the depth of calls, deeper recursion is reported as stack overflow
+const maxFrames = 1 << 16
+
+type frame struct {
+	function *Function
+	ip       int
+	base     int
+}
+
+type machine struct {
+	program *Program
+	output  *bufio.Writer
+	globals []Value
+	stack   []Value
+	frames  []frame
+}
+
+// RuntimeError stops the execution of a program, like a failed bounds check
+type RuntimeError struct {
+	Location string
+	Message  string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Location + e.Message
+}
+
+// Run compiles nodes and executes them, the result of main is returned as exit code
+func Run(file common.SourceFile, nodes []ast.Node, output io.Writer) (int, error) {
+	program, err := Compile(file, nodes)
+	if err != nil {
+		return 1, err
+	}
+	return Execute(program, output)
+}
+
+// Execute runs the top level statements of program and then main
+func Execute(program *Program, output io.Writer) (int, error) {
+	m := &machine{
+		program: program,
+		output:  bufio.NewWriter(output),
+		globals: make([]Value, program.Globals),
+		stack:   make([]Value, 1024),
+	}
+	defer func() {
+		_ = m.output.Flush()
+	}()
+
+	_, err := m.call(program.Init)
+	if err != nil {
+		return 1, err
+	}
+	if program.Main < 0 {
+		return 0, nil
+	}
+
+	result, err := m.call(program.Functions[program.Main])
+	if err != nil {
+		return 1, err
+	}
+	if !program.MainReturns {
+		return 0, nil
+	}
+	return int(result.Int()), nil
+}
+
+// fail reports an error at the instruction before ip
+func (m *machine) fail(function *Function, ip int, message string) error {
+	position := function.Positions[ip-1]
+	location := fmt.Sprintf("[%s:%d:%d] ", filepath.Base(m.program.File.Path), position.Line, position.Column)
+	return &RuntimeError{Location: location, Message: message}
+}
+
+// reserve grows the stack so a frame of function fits at base, its arguments are already pushed
+func (m *machine) reserve(function *Function, base int) {
+	needed := base + function.Locals + function.MaxStack
+	if needed <= len(m.stack) {
+		return
+	}
+	stack := make([]Value, 2*needed)
+	copy(stack, m.stack[:base+function.Parameters])
+	m.stack = stack
+}
+
+// call runs function without arguments until it returns
+func (m *machine) call(function *Function) (Value, error) {
+	m.reserve(function, 0)
+	return m.run(function, 0)
+}
+
+// run is the dispatch loop. The frame of function starts at base and the call returns when it does.
+// Locals are addressed relative to base, the values of expressions are pushed above them.
+func (m *machine) run(function *Function, base int) (Value, error) {
+	constants := m.program.Constants
+	globals := m.globals
+	stack := m.stack
+	code := function.Code
+	ip := 0
+	sp := base + function.Locals
+	entry := len(m.frames)
+
+	for {
+		instruction := code[ip]
+		ip++
+
+		switch instruction.Op() {
+		case OpConstant:
+			stack[sp] = constants[instruction.A()]
+			sp++
+		case OpPop:
+			sp--
+		case OpDup:
+			stack[sp] = stack[sp-1]
+			sp++
+		case OpDupTwo:
+			stack[sp] = stack[sp-2]
+			stack[sp+1] = stack[sp-1]
+			sp += 2
+		case OpCopy:
+			stack[sp-1] = copyValue(stack[sp-1])
+
+		// Variables
+		case OpGetLocal:
+			stack[sp] = stack[base+instruction.A()]
+			sp++
+		case OpSetLocal:
+			sp--
+			stack[base+instruction.A()] = stack[sp]
+		case OpGetGlobal:
+			stack[sp] = globals[instruction.A()]
+			sp++
+		case OpSetGlobal:
+			sp--
+			globals[instruction.A()] = stack[sp]
+
+		// Arithmetic
+		case OpAddInt:
+			sp--
+			stack[sp-1].Bits += stack[sp].Bits
+		case OpSubtractInt:
+			sp--
+			stack[sp-1].Bits -= stack[sp].Bits
+		case OpMultiplyInt:
+			sp--
+			stack[sp-1] = intValue(stack[sp-1].Int() * stack[sp].Int())
+		case OpDivideInt:
+			sp--
+			if stack[sp].Int() == 0 {
+				return Value{}, m.fail(function, ip, "division by zero")
+			}
+			stack[sp-1] = intValue(stack[sp-1].Int() / stack[sp].Int())
+		case OpDivideUint:
+			sp--
+			if stack[sp].Bits == 0 {
+				return Value{}, m.fail(function, ip, "division by zero")
+			}
+			stack[sp-1].Bits /= stack[sp].Bits
+		case OpRemainderInt:
+			sp--
+			if stack[sp].Int() == 0 {
+				return Value{}, m.fail(function, ip, "division by zero")
+			}
+			stack[sp-1] = intValue(stack[sp-1].Int() % stack[sp].Int())
+		case OpRemainderUint:
+			sp--
+			if stack[sp].Bits == 0 {
+				return Value{}, m.fail(function, ip, "division by zero")
+			}
+			stack[sp-1].Bits %= stack[sp].Bits
+		case OpNegateInt:
+			stack[sp-1] = intValue(-stack[sp-1].Int())
+		case OpAddFloat:
+			sp--
+			stack[sp-1] = floatValue(stack[sp-1].Float() + stack[sp].Float())
+		case OpSubtractFloat:
+			sp--
+			stack[sp-1] = floatValue(stack[sp-1].Float() - stack[sp].Float())
+		case OpMultiplyFloat:
+			sp--
+			stack[sp-1] = floatValue(stack[sp-1].Float() * stack[sp].Float())
+		case OpDivideFloat:
+			sp--
+			stack[sp-1] = floatValue(stack[sp-1].Float() / stack[sp].Float())
+		case OpNegateFloat:
+			stack[sp-1] = floatValue(-stack[sp-1].Float())
+		case OpConcat:
+			sp--
+			stack[sp-1] = stringValue(stack[sp-1].String() + stack[sp].String())
+		case OpNot:
+			stack[sp-1] = boolValue(!stack[sp-1].Bool())
+
+		// Bitwise
+		case OpAnd:
+			sp--
+			stack[sp-1].Bits &= stack[sp].Bits
+		case OpOr:
+			sp--
+			stack[sp-1].Bits |= stack[sp].Bits
+		case OpXor:
+			sp--
+			stack[sp-1].Bits ^= stack[sp].Bits
+		case OpComplement:
+			stack[sp-1].Bits = ^stack[sp-1].Bits
+		case OpShiftLeft, OpShiftRightInt, OpShiftRightUint:
+			sp--
+			// Negative counts of signed types are large as unsigned
+			count := stack[sp].Bits
+			if count >= uint64(instruction.A()) {
+				return Value{}, m.fail(function, ip, fmt.Sprintf("shift count out of range for %d bits", instruction.A()))
+			}
+			switch instruction.Op() {
+			case OpShiftLeft:
+				stack[sp-1].Bits <<= count
+			case OpShiftRightInt:
+				stack[sp-1] = intValue(stack[sp-1].Int() >> count)
+			default:
+				stack[sp-1].Bits >>= count
+			}
+
+		// Conversions
+		case OpWrapSigned:
+			shift := 64 - instruction.A()
+			stack[sp-1] = intValue(stack[sp-1].Int() << shift >> shift)
+		case OpWrapUnsigned:
+			stack[sp-1].Bits &= 1<<instruction.A() - 1
+		case OpRoundFloat32:
+			stack[sp-1] = floatValue(float64(float32(stack[sp-1].Float())))
+		case OpIntToFloat:
+			stack[sp-1] = floatValue(float64(stack[sp-1].Int()))
+		case OpUintToFloat:
+			stack[sp-1] = floatValue(float64(stack[sp-1].Bits))
+		case OpFloatToInt:
+			stack[sp-1] = Value{Bits: ast.Number{Kind: ast.Signed, Bits: instruction.A()}.Saturate(stack[sp-1].Float())}
+		case OpFloatToUint:
+			stack[sp-1] = Value{Bits: ast.Number{Kind: ast.Unsigned, Bits: instruction.A()}.Saturate(stack[sp-1].Float())}
+
+		// Comparative
+		case OpEqualInt:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Bits == stack[sp].Bits)
+		case OpNotEqualInt:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Bits != stack[sp].Bits)
+		case OpLowerInt:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Int() < stack[sp].Int())
+		case OpGreaterInt:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Int() > stack[sp].Int())
+		case OpLowerEqualInt:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Int() <= stack[sp].Int())
+		case OpGreaterEqualInt:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Int() >= stack[sp].Int())
+		case OpLowerUint:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Bits < stack[sp].Bits)
+		case OpGreaterUint:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Bits > stack[sp].Bits)
+		case OpLowerEqualUint:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Bits <= stack[sp].Bits)
+		case OpGreaterEqualUint:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Bits >= stack[sp].Bits)
+		case OpEqualFloat:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Float() == stack[sp].Float())
+		case OpNotEqualFloat:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Float() != stack[sp].Float())
+		case OpLowerFloat:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Float() < stack[sp].Float())
+		case OpGreaterFloat:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Float() > stack[sp].Float())
+		case OpLowerEqualFloat:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Float() <= stack[sp].Float())
+		case OpGreaterEqualFloat:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].Float() >= stack[sp].Float())
+		case OpEqualString:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].String() == stack[sp].String())
+		case OpNotEqualString:
+			sp--
+			stack[sp-1] = boolValue(stack[sp-1].String() != stack[sp].String())
+
+		// Control flow
+		case OpJump:
+			ip = instruction.A()
+		case OpJumpIfFalse:
+			sp--
+			if stack[sp].Bits == 0 {
+				ip = instruction.A()
+			}
+		case OpCall:
+			if len(m.frames) >= maxFrames {
+				return Value{}, m.fail(function, ip, "stack overflow")
+			}
+			m.frames = append(m.frames, frame{function: function, ip: ip, base: base})
+
+			function = m.program.Functions[instruction.A()]
+			code = function.Code
+			ip = 0
+			// The arguments become the first locals
+			base = sp - function.Parameters
+			sp = base + function.Locals
+
+			m.reserve(function, base)
+			stack = m.stack
+		case OpReturn, OpReturnVoid:
+			var result Value
+			if instruction.Op() == OpReturn {
+				result = stack[sp-1]
+			}
+			// Drop references of the frame
+			clear(stack[base:sp])
+
+			if len(m.frames) == entry {
+				return result, nil
+			}
+
+			sp = base
+			if instruction.Op() == OpReturn {
+				stack[sp] = result
+				sp++
+			}
+
+			caller := m.frames[len(m.frames)-1]
+			m.frames = m.frames[:len(m.frames)-1]
+			function, code, ip, base = caller.function, caller.function.Code, caller.ip, caller.base
+
+		// Structs, arrays and slices
+		case OpStruct:
+			count := instruction.A()
+			fields := make([]Value, count)
+			copy(fields, stack[sp-count:sp])
+			sp -= count
+			stack[sp] = Value{Ref: &structObject{Fields: fields}}
+			sp++
+		case OpGetField:
+			stack[sp-1] = stack[sp-1].Ref.(*structObject).Fields[instruction.A()]
+		case OpSetField:
+			sp--
+			stack[sp-1].Ref.(*structObject).Fields[instruction.A()] = stack[sp]
+			stack[sp-1] = stack[sp]
+		case OpArray, OpSlice:
+			count := instruction.A()
+			var elements []Value
+			if count > 0 {
+				elements = make([]Value, count)
+				copy(elements, stack[sp-count:sp])
+			}
+			sp -= count
+			if instruction.Op() == OpArray {
+				stack[sp] = Value{Ref: &arrayObject{Elements: elements}}
+			} else {
+				stack[sp] = Value{Ref: &sliceObject{Elements: elements}}
+			}
+			sp++
+		case OpIndex:
+			sp--
+			elements := stack[sp-1].elements()
+			index := stack[sp].Int()
+			if index < 0 || index >= int64(len(elements)) {
+				return Value{}, m.fail(function, ip, fmt.Sprintf("index out of bounds: index %d, length %d", index, len(elements)))
+			}
+			stack[sp-1] = elements[index]
+		case OpSetIndex:
+			sp -= 2
+			elements := stack[sp-1].elements()
+			index := stack[sp].Int()
+			if index < 0 || index >= int64(len(elements)) {
+				return Value{}, m.fail(function, ip, fmt.Sprintf("index out of bounds: index %d, length %d", index, len(elements)))
+			}
+			elements[index] = stack[sp+1]
+			stack[sp-1] = stack[sp+1]
+		case OpLenString:
+			stack[sp-1] = intValue(int64(len(stack[sp-1].String())))
+		case OpLenList:
+			stack[sp-1] = intValue(int64(len(stack[sp-1].elements())))
+		case OpAppend:
+			sp--
+			stack[sp-1] = appendValue(stack[sp-1], stack[sp])
+
+		// Debug statements
+		case OpDebugInt, OpDebugUint, OpDebugFloat, OpDebugBool, OpDebugString:
+			sp--
+			location := constants[instruction.A()].String()
+			_, _ = fmt.Fprintf(m.output, "%s%s\n", location, format(stack[sp], instruction.Op()))
+
+		default:
+			panic(fmt.Sprintf("Unknown opcode %d", instruction.Op()))
+		}
+	}
+}